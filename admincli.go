@@ -0,0 +1,453 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Команды обслуживания: бинарник admin <команда> [флаги] [аргументы].
+// Работают прямо с базой, Telegram не нужен и уведомления не отправляются.
+var adminCommands = []struct {
+	name, args, help string
+	run              func(args []string) error
+}{
+	{"list-queues", "[-all]", "список очередей (с -all — вместе с архивными)", adminListQueues},
+	{"show", "<id очереди>", "настройки и состав очереди", adminShowQueue},
+	{"remove-entry", "<id записи>", "удалить запись из очереди", adminRemoveEntry},
+	{"move", "<id записи> <место>", "переместить запись на место (с 1)", adminMoveEntry},
+	{"export", "[-format csv|xlsx] [-since ДД.ММ.ГГГГ] [-o файл] <id очереди>", "выгрузка очереди и истории приёма", adminExport},
+	{"import", "[-dry-run] <id очереди> <файл>", "импорт списка группы из CSV/XLSX", adminImport},
+	{"vacuum", "", "сжать файл базы", adminVacuum},
+	{"migrate", "", "обновить схему базы до текущей версии", adminMigrate},
+}
+
+func adminUsage(w io.Writer) {
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(w, "Использование: %s admin [-config файл] [-db путь] <команда> [аргументы]\n"+
+		"База берётся из настроек: флаг -db, DB_PATH, database.dsn в файле или %s.\n\nКоманды:\n", name, defaultDBPath)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range adminCommands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", c.name, c.args, c.help)
+	}
+	tw.Flush()
+}
+
+// Точка входа admin: возвращает код выхода процесса
+func runAdminCLI(args []string) int {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	flags := registerConfigFlags(fs)
+	fs.Usage = func() { adminUsage(fs.Output()) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// Те же настройки, что и у бота, но токен не нужен
+	cfg, err := loadConfig(fs, flags)
+	if err == nil {
+		err = cfg.validate(false)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибки в настройках:\n%v\n", err)
+		return 1
+	}
+	config = cfg
+	if *flags.printConfig {
+		if err := printConfig(os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if fs.NArg() == 0 {
+		adminUsage(os.Stderr)
+		return 2
+	}
+
+	name := fs.Arg(0)
+	for _, c := range adminCommands {
+		if c.name != name {
+			continue
+		}
+		if location, err = loadLocation(); err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка часового пояса: %v\n", err)
+			return 1
+		}
+		if db, err = openDB(config.Database.Driver, config.Database.DSN); err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка базы данных: %v\n", err)
+			return 1
+		}
+		defer db.Close()
+
+		// Со старой или более новой схемой работает только migrate
+		if name != "migrate" {
+			if err := checkSchemaVersion(); err != nil {
+				fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+				return 1
+			}
+		}
+		if err := c.run(fs.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+			if errors.Is(err, errAdminUsage) {
+				fmt.Fprintf(os.Stderr, "Использование: %s admin %s %s\n", filepath.Base(os.Args[0]), c.name, c.args)
+				return 2
+			}
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "Неизвестная команда %q\n\n", name)
+	adminUsage(os.Stderr)
+	return 2
+}
+
+var errAdminUsage = errors.New("неверные аргументы")
+
+func schemaVersion() (int, error) {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+func checkSchemaVersion() error {
+	version, err := schemaVersion()
+	if err != nil {
+		return err
+	}
+	switch {
+	case version < len(migrations):
+		return fmt.Errorf("схема базы устарела (версия %d из %d), сначала выполните admin migrate", version, len(migrations))
+	case version > len(migrations):
+		return fmt.Errorf("схема базы (версия %d) новее этой программы (%d)", version, len(migrations))
+	}
+	return nil
+}
+
+// Разбор флагов и ровно n позиционных аргументов команды
+func parseAdminArgs(fs *flag.FlagSet, args []string, n int) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errAdminUsage, err)
+	}
+	if fs.NArg() != n {
+		return errAdminUsage
+	}
+	return nil
+}
+
+func parseAdminID(arg, what string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: %s должен быть положительным числом, а не %q", errAdminUsage, what, arg)
+	}
+	return id, nil
+}
+
+func adminListQueues(args []string) error {
+	fs := flag.NewFlagSet("list-queues", flag.ContinueOnError)
+	all := fs.Bool("all", false, "")
+	if err := parseAdminArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`SELECT q.id, q.name, q.status, COALESCE(c.name, ''),
+		(SELECT COUNT(*) FROM queue_entries e WHERE e.queue_id = q.id)
+	FROM queues q LEFT JOIN courses c ON c.id = q.course_id
+	WHERE ? OR q.status != ? ORDER BY q.id`, *all, queueStatusArchived)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tНАЗВАНИЕ\tСТАТУС\tКУРС\tЗАПИСЕЙ")
+	for rows.Next() {
+		var id, count int
+		var name, status, course string
+		if err := rows.Scan(&id, &name, &status, &course, &count); err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\n", id, name, queueStatusNames[status], course, count)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+func adminShowQueue(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	if err := parseAdminArgs(fs, args, 1); err != nil {
+		return err
+	}
+	queueID, err := parseAdminID(fs.Arg(0), "id очереди")
+	if err != nil {
+		return err
+	}
+	q, err := adminLoadQueue(queueID)
+	if err != nil {
+		return err
+	}
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(strings.TrimRight(formatQueueInfo(q), "\n"))
+	fmt.Println()
+	if len(entries) == 0 {
+		fmt.Println("Очередь пуста.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "МЕСТО\tID ЗАПИСИ\tУЧАСТНИК\tUSER ID\tЗАПИСАН\tЛАБЫ\tПРИОРИТЕТ")
+	for i, e := range entries {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%d\t%s\t%s\t%d\n", i+1, e.ID, entryLabel(e), e.UserID, formatTime(e.JoinedAt), formatLabList(e.Labs), e.Priority)
+	}
+	return tw.Flush()
+}
+
+func adminLoadQueue(queueID int) (Queue, error) {
+	q, err := loadQueue(queueID)
+	if err == sql.ErrNoRows {
+		return q, fmt.Errorf("очереди %d нет", queueID)
+	}
+	return q, err
+}
+
+// Поиск записи по ID: возвращает очередь и место (с 1)
+func adminFindEntry(entryID int) (QueueEntry, int, error) {
+	var queueID int
+	err := db.QueryRow("SELECT queue_id FROM queue_entries WHERE id = ?", entryID).Scan(&queueID)
+	if err == sql.ErrNoRows {
+		return QueueEntry{}, 0, fmt.Errorf("записи %d нет", entryID)
+	}
+	if err != nil {
+		return QueueEntry{}, 0, err
+	}
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		return QueueEntry{}, 0, err
+	}
+	for i, e := range entries {
+		if e.ID == entryID {
+			return e, i + 1, nil
+		}
+	}
+	return QueueEntry{}, 0, fmt.Errorf("записи %d нет", entryID)
+}
+
+func adminRemoveEntry(args []string) error {
+	fs := flag.NewFlagSet("remove-entry", flag.ContinueOnError)
+	if err := parseAdminArgs(fs, args, 1); err != nil {
+		return err
+	}
+	entryID, err := parseAdminID(fs.Arg(0), "id записи")
+	if err != nil {
+		return err
+	}
+	e, place, err := adminFindEntry(entryID)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM queue_entries WHERE id = ?", entryID); err != nil {
+		return err
+	}
+	fmt.Printf("Запись %d (%s, место %d) удалена из очереди %d.\n", entryID, entryLabel(e), place, e.QueueID)
+	return nil
+}
+
+func adminMoveEntry(args []string) error {
+	fs := flag.NewFlagSet("move", flag.ContinueOnError)
+	if err := parseAdminArgs(fs, args, 2); err != nil {
+		return err
+	}
+	entryID, err := parseAdminID(fs.Arg(0), "id записи")
+	if err != nil {
+		return err
+	}
+	position, err := parseAdminID(fs.Arg(1), "место")
+	if err != nil {
+		return err
+	}
+	e, place, err := adminFindEntry(entryID)
+	if err != nil {
+		return err
+	}
+	if err := moveEntry(e.QueueID, entryID, position-1); err != nil {
+		return err
+	}
+	_, newPlace, err := adminFindEntry(entryID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Запись %d (%s) перемещена с места %d на место %d.\n", entryID, entryLabel(e), place, newPlace)
+	return nil
+}
+
+func adminExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "")
+	sinceText := fs.String("since", "", "")
+	out := fs.String("o", "", "")
+	if err := parseAdminArgs(fs, args, 1); err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "xlsx" {
+		return fmt.Errorf("%w: формат %q, нужен csv или xlsx", errAdminUsage, *format)
+	}
+	if *format == "xlsx" && *out == "" {
+		return fmt.Errorf("%w: для xlsx укажите файл через -o", errAdminUsage)
+	}
+	var since time.Time
+	if *sinceText != "" {
+		var err error
+		if since, err = time.ParseInLocation("02.01.2006", *sinceText, location); err != nil {
+			return fmt.Errorf("%w: дата %q, нужен формат ДД.ММ.ГГГГ", errAdminUsage, *sinceText)
+		}
+	}
+	queueID, err := parseAdminID(fs.Arg(0), "id очереди")
+	if err != nil {
+		return err
+	}
+	q, err := adminLoadQueue(queueID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := queueExportRows(q, since)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if *format == "xlsx" {
+		data, err = writeXLSX("Очередь", rows)
+	} else {
+		data, err = encodeCSV(rows)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Строк в выгрузке: %d, файл %s.\n", len(rows)-1, *out)
+	return nil
+}
+
+func adminImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "")
+	if err := parseAdminArgs(fs, args, 2); err != nil {
+		return err
+	}
+	queueID, err := parseAdminID(fs.Arg(0), "id очереди")
+	if err != nil {
+		return err
+	}
+	q, err := adminLoadQueue(queueID)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	rows, err := readSpreadsheet(fs.Arg(1), data)
+	if err != nil {
+		return err
+	}
+	entries, errs := parseRosterTable(rows)
+	for _, e := range errs {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	fmt.Printf("Готово к импорту: %d, строк с ошибками: %d.\n", len(entries), len(errs))
+	if *dryRun || len(entries) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	added, err := addRosterEntries(tx, q, entries)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("Импортировано: %d, уже были в списке: %d.\n", added, len(entries)-added)
+	return nil
+}
+
+func adminVacuum(args []string) error {
+	fs := flag.NewFlagSet("vacuum", flag.ContinueOnError)
+	if err := parseAdminArgs(fs, args, 0); err != nil {
+		return err
+	}
+	before, err := databaseSize()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return err
+	}
+	after, err := databaseSize()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Размер базы: %d КБ → %d КБ.\n", before/1024, after/1024)
+	return nil
+}
+
+func databaseSize() (int64, error) {
+	var pages, pageSize int64
+	if err := db.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pages * pageSize, nil
+}
+
+func adminMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	if err := parseAdminArgs(fs, args, 0); err != nil {
+		return err
+	}
+	before, err := schemaVersion()
+	if err != nil {
+		return err
+	}
+	if before > len(migrations) {
+		return fmt.Errorf("схема базы (версия %d) новее этой программы (%d)", before, len(migrations))
+	}
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	if before == len(migrations) {
+		fmt.Printf("Схема уже актуальна (версия %d).\n", before)
+		return nil
+	}
+	fmt.Printf("Схема обновлена: версия %d → %d.\n", before, len(migrations))
+	return nil
+}
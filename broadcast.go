@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Черновик рассылки: текст или сообщение, которое будет скопировано
+type broadcastDraft struct {
+	text       string
+	fromChatID int64
+	messageID  int
+}
+
+var broadcastDrafts = make(map[int64]broadcastDraft) // userID -> черновик рассылки
+
+func startBroadcast(bot *tgbotapi.BotAPI, chatID int64) {
+	userStates[chatID] = "admin_broadcast"
+	msg := tgbotapi.NewMessage(chatID, "Отправьте текст для всех участников очереди или перешлите сообщение (можно с фото или файлом):")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+	)
+	send(bot, msg)
+}
+
+// Получатели рассылки: все, кто записан в очередь и писал боту
+func queueMemberIDs(queueID int) ([]int64, error) {
+	rows, err := db.Query("SELECT DISTINCT user_id FROM queue_entries WHERE queue_id = ? AND user_id != 0", queueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// Сообщение для рассылки получено: показываем, как его увидят участники
+func handleBroadcastMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	if message.Text == "Назад в главное меню" {
+		backToAdminMenu(bot, chatID, queueID, "Рассылка отменена.")
+		return
+	}
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при подготовке рассылки.")
+		return
+	}
+	userIDs, err := queueMemberIDs(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки участников: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при подготовке рассылки.")
+		return
+	}
+	if len(userIDs) == 0 {
+		backToAdminMenu(bot, chatID, queueID, "В очереди нет участников, которым можно написать.")
+		return
+	}
+
+	// Текст подписываем названием очереди, остальное копируем как есть
+	var draft broadcastDraft
+	if message.Text != "" {
+		draft.text = fmt.Sprintf("Сообщение для очереди \"%s\":\n\n%s", q.Name, message.Text)
+		send(bot, tgbotapi.NewMessage(chatID, draft.text))
+	} else {
+		draft.fromChatID, draft.messageID = chatID, message.MessageID
+		send(bot, tgbotapi.NewCopyMessage(chatID, chatID, message.MessageID))
+	}
+	broadcastDrafts[chatID] = draft
+
+	userStates[chatID] = "admin_broadcast_confirm"
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Так сообщение увидят участники. Отправить? Получателей: %d.", len(userIDs)))
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Отправить"), tgbotapi.NewKeyboardButton("Отмена")),
+	)
+	send(bot, msg)
+}
+
+func handleBroadcastConfirm(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+	draft, ok := broadcastDrafts[chatID]
+
+	switch message.Text {
+	case "Отправить":
+	case "Отмена", "Назад в главное меню":
+		delete(broadcastDrafts, chatID)
+		backToAdminMenu(bot, chatID, queueID, "Рассылка отменена.")
+		return
+	default:
+		send(bot, tgbotapi.NewMessage(chatID, "Нажмите «Отправить» или «Отмена»."))
+		return
+	}
+	delete(broadcastDrafts, chatID)
+	if !ok {
+		backToAdminMenu(bot, chatID, queueID, "Рассылка отменена.")
+		return
+	}
+
+	// Состав очереди мог измениться, пока смотрели предпросмотр
+	userIDs, err := queueMemberIDs(queueID)
+	var name string
+	if err == nil {
+		err = db.QueryRow("SELECT name FROM queues WHERE id = ?", queueID).Scan(&name)
+	}
+	if err == nil {
+		if draft.text != "" {
+			_, err = enqueueBroadcast(chatID, name, userIDs, draft.text)
+		} else {
+			_, err = enqueueCopyBroadcast(chatID, name, userIDs, draft.fromChatID, draft.messageID)
+		}
+	}
+	if err != nil {
+		log.Printf("Ошибка создания рассылки: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при создании рассылки.")
+		return
+	}
+	backToAdminMenu(bot, chatID, queueID, fmt.Sprintf("Рассылка поставлена в очередь, получателей: %d. Когда она закончится, пришлю отчёт.", len(userIDs)))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Вызов участника: его очередь подошла.
+// calledBy — чат преподавателя, которому сообщаем об ответе или неявке.
+func callEntry(bot *tgbotapi.BotAPI, e QueueEntry, calledBy int64) {
+	q, err := loadQueue(e.QueueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		return
+	}
+
+	// Вызов отмечаем всегда: по called_by следующий вызывается, когда первый
+	// уходит или пропускает вперёд. Таймаут на ответ проверяется, только если
+	// он задан в очереди. Записи без пользователя подтвердить вызов некому.
+	if e.UserID != 0 {
+		_, err := db.Exec("UPDATE queue_entries SET called_at = ?, called_by = ?, confirmed = 0 WHERE id = ?",
+			time.Now().UTC(), calledBy, e.ID)
+		if err != nil {
+			log.Printf("Ошибка отметки вызова: %v", err)
+		}
+	}
+
+	announceToGroup(db, q, fmt.Sprintf("Очередь \"%s\": вызывается %s.", q.Name, entryLabel(e)))
+
+	if e.UserID == 0 {
+		return
+	}
+
+	text := fmt.Sprintf("Ваша очередь в \"%s\" подошла, проходите!", q.Name)
+	keyboard := deferKeyboard(e.QueueID)
+	if q.CallTimeout > 0 {
+		text += fmt.Sprintf("\nНажмите «Иду» в течение %d мин., иначе вас сдвинут назад.", q.CallTimeout)
+		keyboard.InlineKeyboard[0] = slices.Insert(keyboard.InlineKeyboard[0], 0,
+			tgbotapi.NewInlineKeyboardButtonData("Иду", fmt.Sprintf("call_ack:%d", e.ID)))
+	}
+	text += "\nЕсли ещё не готовы, можно пропустить следующего вперёд."
+
+	msg := tgbotapi.NewMessage(e.UserID, text)
+	msg.ReplyMarkup = keyboard
+	send(bot, msg)
+}
+
+// Подтверждение вызова кнопкой «Иду»
+func acknowledgeCall(bot *tgbotapi.BotAPI, entryID int, userID int64) string {
+	var calledBy sql.NullInt64
+	var username sql.NullString
+	err := db.QueryRow("SELECT called_by, username FROM queue_entries WHERE id = ? AND user_id = ? AND called_at IS NOT NULL",
+		entryID, userID).Scan(&calledBy, &username)
+	if err == sql.ErrNoRows {
+		return "Вызов уже не действует."
+	}
+	if err != nil {
+		log.Printf("Ошибка подтверждения вызова: %v", err)
+		return "Ошибка при подтверждении."
+	}
+
+	if _, err := db.Exec("UPDATE queue_entries SET confirmed = 1 WHERE id = ?", entryID); err != nil {
+		log.Printf("Ошибка подтверждения вызова: %v", err)
+		return "Ошибка при подтверждении."
+	}
+
+	if calledBy.Int64 != 0 {
+		send(bot, tgbotapi.NewMessage(calledBy.Int64, fmt.Sprintf("%s подтверждает вызов и уже идёт.", entryLabel(QueueEntry{UserID: userID, Username: username.String}))))
+	}
+	return "Ждём вас!"
+}
+
+type missedCall struct {
+	entry      QueueEntry
+	calledBy   int64
+	noShows    int
+	maxNoShows int
+}
+
+// Обработка неявок: кто не подтвердил вызов вовремя, сдвигается назад,
+// а после нескольких неявок подряд удаляется из очереди
+func checkMissedCalls(bot *tgbotapi.BotAPI, now time.Time) error {
+	rows, err := db.Query(`
+	SELECT e.id, e.queue_id, e.user_id, e.username, e.called_at, COALESCE(e.called_by, 0), e.no_shows, q.call_timeout, q.max_no_shows
+	FROM queue_entries e JOIN queues q ON q.id = e.queue_id
+	WHERE e.called_at IS NOT NULL AND e.confirmed = 0 AND q.call_timeout > 0`)
+	if err != nil {
+		return err
+	}
+	var missed []missedCall
+	for rows.Next() {
+		var m missedCall
+		var username sql.NullString
+		var calledAt time.Time
+		var timeout int
+		if err := rows.Scan(&m.entry.ID, &m.entry.QueueID, &m.entry.UserID, &username, &calledAt,
+			&m.calledBy, &m.noShows, &timeout, &m.maxNoShows); err != nil {
+			rows.Close()
+			return err
+		}
+		m.entry.Username = username.String
+		if calledAt.Add(time.Duration(timeout) * time.Minute).Before(now) {
+			missed = append(missed, m)
+		}
+	}
+	rows.Close()
+
+	for _, m := range missed {
+		if err := handleMissedCall(bot, m); err != nil {
+			log.Printf("Ошибка обработки неявки: %v", err)
+		}
+	}
+	return nil
+}
+
+func handleMissedCall(bot *tgbotapi.BotAPI, m missedCall) error {
+	var name string
+	if err := db.QueryRow("SELECT name FROM queues WHERE id = ?", m.entry.QueueID).Scan(&name); err != nil {
+		return err
+	}
+
+	// Следующего вызываем, только если не явившийся стоял первым
+	entries, err := loadEntries(m.entry.QueueID)
+	if err != nil {
+		return err
+	}
+	wasHead := len(entries) > 0 && entries[0].ID == m.entry.ID
+
+	removed := m.maxNoShows > 0 && m.noShows+1 >= m.maxNoShows
+	var userText, adminText string
+	if removed {
+		if _, err := db.Exec("DELETE FROM queue_entries WHERE id = ?", m.entry.ID); err != nil {
+			return err
+		}
+		userText = fmt.Sprintf("Вы не ответили на вызов в очереди \"%s\" и были удалены из неё.", name)
+		adminText = fmt.Sprintf("Неявка: %s удаляется из очереди.", entryLabel(m.entry))
+	} else {
+		if err := moveEntry(m.entry.QueueID, m.entry.ID, 1); err != nil {
+			return err
+		}
+		_, err := db.Exec("UPDATE queue_entries SET called_at = NULL, called_by = NULL, confirmed = 0, no_shows = no_shows + 1 WHERE id = ?", m.entry.ID)
+		if err != nil {
+			return err
+		}
+		userText = fmt.Sprintf("Вы не ответили на вызов в очереди \"%s\" и сдвинуты на одно место назад.", name)
+		adminText = fmt.Sprintf("Нет ответа от %s — запись сдвинута на одно место назад.", entryLabel(m.entry))
+	}
+
+	if entries, err = loadEntries(m.entry.QueueID); err != nil {
+		return err
+	}
+	if !removed && len(entries) > 0 && entries[0].ID == m.entry.ID {
+		userText = fmt.Sprintf("Вы не ответили на вызов в очереди \"%s\". Сдвинуть запись некуда, поэтому вызов повторяется.", name)
+	}
+
+	if m.entry.UserID != 0 {
+		send(bot, tgbotapi.NewMessage(m.entry.UserID, userText))
+	}
+	// Если сдвигать некуда и запись осталась первой, вызываем её повторно,
+	// чтобы очередь не остановилась без вызванного
+	if wasHead && len(entries) > 0 {
+		if entries[0].ID == m.entry.ID {
+			adminText += "\nВ очереди больше никого нет — вызов повторён."
+		} else {
+			adminText += fmt.Sprintf("\nВызван следующий: %s%s.", entryLabel(entries[0]), entryLabsText(entries[0]))
+		}
+		callEntry(bot, entries[0], m.calledBy)
+	}
+	if m.calledBy != 0 {
+		send(bot, tgbotapi.NewMessage(m.calledBy, adminText))
+	}
+	return nil
+}
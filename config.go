@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultConfigFile = "config.yaml"
+	redactedValue     = "<скрыто>"
+
+	semesterStartLayout = "02.01.2006"
+)
+
+// Настройки бота. Источники по возрастанию приоритета: значения по умолчанию,
+// файл config.yaml, переменные окружения, флаги командной строки.
+type Config struct {
+	Token       string  `yaml:"token"`
+	Debug       bool    `yaml:"debug"`
+	PollTimeout int     `yaml:"poll_timeout"` // секунд ожидания в getUpdates
+	TimeZone    string  `yaml:"time_zone"`
+	Admins      []int64 `yaml:"admins"` // user ID тех, кто управляет любыми очередями и курсами
+
+	// Начало семестра (ДД.ММ.ГГГГ) для чётности недель в расписании.
+	// Пусто — чётность по номеру недели ISO.
+	SemesterStart string `yaml:"semester_start"`
+
+	Database struct {
+		Driver string `yaml:"driver"`
+		DSN    string `yaml:"dsn"`
+	} `yaml:"database"`
+
+	RateLimits struct {
+		GlobalRate  float64 `yaml:"global_rate"`  // сообщений в секунду всего
+		GlobalBurst float64 `yaml:"global_burst"` // сколько можно отправить сразу
+		ChatRate    float64 `yaml:"chat_rate"`    // сообщений в секунду в один чат
+		ChatBurst   float64 `yaml:"chat_burst"`
+	} `yaml:"rate_limits"`
+
+	Features struct {
+		Groups    bool `yaml:"groups"`    // привязка очередей к группам
+		Rosters   bool `yaml:"rosters"`   // списки групп и заявки
+		Broadcast bool `yaml:"broadcast"` // «Написать всем в очереди»
+		Export    bool `yaml:"export"`    // выгрузка в CSV/XLSX
+		Invites   bool `yaml:"invites"`   // ссылки-приглашения с токенами
+	} `yaml:"features"`
+}
+
+// Текущие настройки. До загрузки — значения по умолчанию.
+var config = defaultConfig()
+
+func defaultConfig() Config {
+	var c Config
+	c.Debug = true
+	c.PollTimeout = 60
+	c.TimeZone = defaultTimeZone
+	c.Database.Driver = "sqlite3"
+	c.Database.DSN = defaultDBPath
+	c.RateLimits.GlobalRate = globalRate
+	c.RateLimits.GlobalBurst = globalBurst
+	c.RateLimits.ChatRate = chatRate
+	c.RateLimits.ChatBurst = chatBurst
+	c.Features.Groups = true
+	c.Features.Rosters = true
+	c.Features.Broadcast = true
+	c.Features.Export = true
+	c.Features.Invites = true
+	return c
+}
+
+// Флаги, общие для бота и команд admin
+type configFlags struct {
+	file        *string
+	token       *string
+	dbDriver    *string
+	dsn         *string
+	debug       *bool
+	pollTimeout *int
+	timeZone    *string
+	admins      *string
+	printConfig *bool
+}
+
+func registerConfigFlags(fs *flag.FlagSet) *configFlags {
+	return &configFlags{
+		file:        fs.String("config", "", "файл настроек YAML (по умолчанию "+defaultConfigFile+", если он есть; или CONFIG_FILE)"),
+		token:       fs.String("token", "", "токен бота (лучше задавать через API_KEY)"),
+		dbDriver:    fs.String("db-driver", "", "драйвер базы данных"),
+		dsn:         fs.String("db", "", "путь к файлу базы (DSN)"),
+		debug:       fs.Bool("debug", false, "подробный лог запросов к Telegram"),
+		pollTimeout: fs.Int("poll-timeout", 0, "секунд ожидания обновлений"),
+		timeZone:    fs.String("tz", "", "часовой пояс, например Europe/Moscow"),
+		admins:      fs.String("admins", "", "user ID администраторов через запятую"),
+		printConfig: fs.Bool("print-config", false, "вывести итоговые настройки (без секретов) и выйти"),
+	}
+}
+
+// Сборка настроек: файл, окружение, затем флаги, которые заданы явно
+func loadConfig(fs *flag.FlagSet, flags *configFlags) (Config, error) {
+	c := defaultConfig()
+
+	path, explicit := *flags.file, *flags.file != ""
+	if !explicit {
+		path = os.Getenv("CONFIG_FILE")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = defaultConfigFile
+	}
+	if err := c.readFile(path); err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			return c, err
+		}
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return c, err
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "token":
+			c.Token = *flags.token
+		case "db-driver":
+			c.Database.Driver = *flags.dbDriver
+		case "db":
+			c.Database.DSN = *flags.dsn
+		case "debug":
+			c.Debug = *flags.debug
+		case "poll-timeout":
+			c.PollTimeout = *flags.pollTimeout
+		case "tz":
+			c.TimeZone = *flags.timeZone
+		case "admins":
+			if c.Admins, err = parseAdminIDs(*flags.admins); err != nil {
+				err = fmt.Errorf("флаг -admins: %w", err)
+			}
+		}
+	})
+	return c, err
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true) // опечатка в ключе не должна молча игнорироваться
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("файл настроек %s: %w", path, err)
+	}
+	return nil
+}
+
+// Переменные окружения; API_KEY, DB_PATH, TIME_ZONE и SEMESTER_START работали и раньше
+func (c *Config) applyEnv() error {
+	strs := []struct {
+		name string
+		dst  *string
+	}{
+		{"API_KEY", &c.Token},
+		{"DB_DRIVER", &c.Database.Driver},
+		{"DB_PATH", &c.Database.DSN},
+		{"TIME_ZONE", &c.TimeZone},
+		{"SEMESTER_START", &c.SemesterStart},
+	}
+	for _, s := range strs {
+		if v, ok := os.LookupEnv(s.name); ok && v != "" {
+			*s.dst = v
+		}
+	}
+
+	if v := os.Getenv("BOT_DEBUG"); v != "" {
+		debug, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("BOT_DEBUG: ожидается true или false, а не %q", v)
+		}
+		c.Debug = debug
+	}
+	if v := os.Getenv("POLL_TIMEOUT"); v != "" {
+		timeout, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("POLL_TIMEOUT: ожидается число секунд, а не %q", v)
+		}
+		c.PollTimeout = timeout
+	}
+	if v := os.Getenv("ADMINS"); v != "" {
+		admins, err := parseAdminIDs(v)
+		if err != nil {
+			return fmt.Errorf("ADMINS: %w", err)
+		}
+		c.Admins = admins
+	}
+	return nil
+}
+
+func parseAdminIDs(text string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' }) {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("не понимаю user ID %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Проверка настроек при запуске. Токен нужен только боту, командам admin — нет.
+func (c Config) validate(requireToken bool) error {
+	var errs []error
+	if requireToken && c.Token == "" {
+		errs = append(errs, errors.New("не задан токен бота: API_KEY, token в файле настроек или флаг -token"))
+	}
+	if c.Database.Driver != "sqlite3" {
+		errs = append(errs, fmt.Errorf("database.driver: поддерживается только sqlite3, а не %q", c.Database.Driver))
+	}
+	if c.Database.DSN == "" {
+		errs = append(errs, errors.New("database.dsn: не задан путь к базе"))
+	}
+	if c.PollTimeout < 1 || c.PollTimeout > 600 {
+		errs = append(errs, fmt.Errorf("poll_timeout: нужно от 1 до 600 секунд, а не %d", c.PollTimeout))
+	}
+	if _, err := time.LoadLocation(c.TimeZone); err != nil || c.TimeZone == "" {
+		errs = append(errs, fmt.Errorf("time_zone: неизвестный часовой пояс %q", c.TimeZone))
+	}
+	if c.SemesterStart != "" {
+		if _, err := time.Parse(semesterStartLayout, c.SemesterStart); err != nil {
+			errs = append(errs, fmt.Errorf("semester_start: нужна дата ДД.ММ.ГГГГ, а не %q", c.SemesterStart))
+		}
+	}
+	for _, id := range c.Admins {
+		if id <= 0 {
+			errs = append(errs, fmt.Errorf("admins: user ID должен быть положительным, а не %d", id))
+		}
+	}
+
+	r := c.RateLimits
+	if r.GlobalRate <= 0 || r.GlobalBurst < 1 || r.ChatRate <= 0 || r.ChatBurst < 1 {
+		errs = append(errs, errors.New("rate_limits: скорости должны быть больше нуля, а всплески — не меньше 1"))
+	} else if r.ChatRate > r.GlobalRate {
+		errs = append(errs, errors.New("rate_limits: chat_rate не может быть больше global_rate"))
+	}
+	return errors.Join(errs...)
+}
+
+// Копия настроек для вывода: секреты скрыты
+func (c Config) redacted() Config {
+	if c.Token != "" {
+		c.Token = redactedValue
+	}
+	return c
+}
+
+func printConfig(w io.Writer, c Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Глобальные администраторы из настроек
+func isBotAdmin(userID int64) bool {
+	return slices.Contains(config.Admins, userID)
+}
+
+// Общими очередями и расписанием управляют глобальные администраторы.
+// Пока они не заданы, как и раньше, это доступно всем.
+func canManageGlobal(userID int64) bool {
+	return len(config.Admins) == 0 || isBotAdmin(userID)
+}
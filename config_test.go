@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateSemesterStart(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"", false},
+		{"01.09.2026", false},
+		{"2026-09-01", true},
+		{"31.02.2026", true},
+	}
+	for _, tt := range tests {
+		c := defaultConfig()
+		c.SemesterStart = tt.value
+		err := c.validate(false)
+		if gotErr := err != nil && strings.Contains(err.Error(), "semester_start"); gotErr != tt.wantErr {
+			t.Errorf("semester_start %q: ошибка %v", tt.value, err)
+		}
+	}
+}
+
+func TestIsOddWeekFromSemesterStart(t *testing.T) {
+	prev := config.SemesterStart
+	t.Cleanup(func() { config.SemesterStart = prev })
+	config.SemesterStart = "02.09.2026" // среда
+
+	tests := []struct {
+		day  time.Time
+		want bool
+	}{
+		{time.Date(2026, 8, 31, 10, 0, 0, 0, location), true}, // понедельник первой недели
+		{time.Date(2026, 9, 6, 10, 0, 0, 0, location), true},  // воскресенье первой недели
+		{time.Date(2026, 9, 7, 10, 0, 0, 0, location), false},
+		{time.Date(2026, 9, 14, 10, 0, 0, 0, location), true},
+	}
+	for _, tt := range tests {
+		if got := isOddWeek(tt.day); got != tt.want {
+			t.Errorf("isOddWeek(%s) = %v, want %v", tt.day.Format("02.01"), got, tt.want)
+		}
+	}
+}
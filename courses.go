@@ -0,0 +1,361 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	courseRoleStudent = "student"
+	courseRoleAdmin   = "admin"
+)
+
+var courseRoleNames = map[string]string{
+	courseRoleStudent: "участник",
+	courseRoleAdmin:   "администратор",
+}
+
+// Очередь видна, если она общая или пользователь записан на её курс
+const visibleQueueCondition = "(q.course_id IS NULL OR q.course_id IN (SELECT course_id FROM course_members WHERE user_id = ?))"
+
+// Проверка, видна ли пользователю очередь
+func queueVisible(queueID int, userID int64) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM queues q WHERE q.id = ? AND "+visibleQueueCondition, queueID, userID).Scan(&count)
+	return count > 0, err
+}
+
+var errCourseNameTaken = errors.New("Курс с таким названием уже есть. Выберите другое.")
+
+// Курс или группа: владеет очередями, участники приходят по ссылке-приглашению
+type Course struct {
+	ID         int
+	Name       string
+	InviteCode string
+	Role       string // роль текущего пользователя
+}
+
+var pendingQueueNames = make(map[int64]string) // userID -> название создаваемой очереди
+
+func newInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
+
+func courseInviteLink(bot *tgbotapi.BotAPI, c Course) string {
+	return fmt.Sprintf("https://t.me/%s?start=course_%s", bot.Self.UserName, c.InviteCode)
+}
+
+// Создание курса: создатель становится его администратором
+func createCourse(name string, userID int64) (Course, error) {
+	c := Course{Name: name, Role: courseRoleAdmin}
+	var err error
+	if c.InviteCode, err = newInviteCode(); err != nil {
+		return c, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM courses WHERE name_key = ?", queueNameKey(name)).Scan(&count); err != nil {
+		return c, err
+	}
+	if count > 0 {
+		return c, errCourseNameTaken
+	}
+
+	res, err := tx.Exec("INSERT INTO courses (name, name_key, invite_code, created_by, created_at) VALUES (?, ?, ?, ?, ?)",
+		name, queueNameKey(name), c.InviteCode, userID, time.Now().UTC())
+	if err != nil {
+		return c, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return c, err
+	}
+	c.ID = int(id)
+	if err := addCourseMember(tx, c.ID, userID, courseRoleAdmin); err != nil {
+		return c, err
+	}
+	return c, tx.Commit()
+}
+
+// Добавление в курс. Роль администратора не понижается.
+func addCourseMember(ex execer, courseID int, userID int64, role string) error {
+	_, err := ex.Exec(`INSERT INTO course_members (course_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT(course_id, user_id) DO UPDATE SET role = CASE WHEN role = ? THEN role ELSE excluded.role END`,
+		courseID, userID, role, time.Now().UTC(), courseRoleAdmin)
+	return err
+}
+
+// Курсы пользователя с его ролью в каждом
+func userCourses(userID int64) ([]Course, error) {
+	rows, err := db.Query(`SELECT c.id, c.name, c.invite_code, m.role FROM courses c
+	JOIN course_members m ON m.course_id = c.id WHERE m.user_id = ? ORDER BY c.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var courses []Course
+	for rows.Next() {
+		var c Course
+		if err := rows.Scan(&c.ID, &c.Name, &c.InviteCode, &c.Role); err != nil {
+			return nil, err
+		}
+		courses = append(courses, c)
+	}
+	return courses, rows.Err()
+}
+
+func adminCourses(userID int64) ([]Course, error) {
+	courses, err := userCourses(userID)
+	var admin []Course
+	for _, c := range courses {
+		if c.Role == courseRoleAdmin {
+			admin = append(admin, c)
+		}
+	}
+	return admin, err
+}
+
+func isCourseAdmin(userID int64, courseID int) (bool, error) {
+	if isBotAdmin(userID) {
+		return true, nil
+	}
+	var role string
+	err := db.QueryRow("SELECT role FROM course_members WHERE course_id = ? AND user_id = ?", courseID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return role == courseRoleAdmin, err
+}
+
+// Управлять очередью курса могут только администраторы курса.
+// Общие очереди, как и раньше, доступны всем.
+func canManageQueue(userID int64, q Queue) (bool, error) {
+	if q.CourseID == 0 {
+		return canManageGlobal(userID), nil
+	}
+	return isCourseAdmin(userID, q.CourseID)
+}
+
+// Вступление в курс по коду из ссылки-приглашения
+func joinCourseByInvite(bot *tgbotapi.BotAPI, chatID int64, code string) {
+	var c Course
+	err := db.QueryRow("SELECT id, name FROM courses WHERE invite_code = ?", code).Scan(&c.ID, &c.Name)
+	if err == sql.ErrNoRows {
+		msg := tgbotapi.NewMessage(chatID, "Ссылка-приглашение недействительна. Попросите у преподавателя новую.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+	if err == nil {
+		err = addCourseMember(db, c.ID, chatID, courseRoleStudent)
+	}
+	if err != nil {
+		log.Printf("Ошибка вступления в курс: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при вступлении в курс.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Вы в курсе «%s». Теперь в списке видны его очереди.", c.Name))
+	msg.ReplyMarkup = mainMenu()
+	send(bot, msg)
+}
+
+func showCoursesMenu(bot *tgbotapi.BotAPI, chatID int64) {
+	courses, err := userCourses(chatID)
+	if err != nil {
+		log.Printf("Ошибка загрузки курсов: %v", err)
+		send(bot, tgbotapi.NewMessage(chatID, "Ошибка при загрузке курсов."))
+		return
+	}
+
+	userStates[chatID] = "courses_menu"
+	keyboard := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Создать курс")),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+	)
+	if len(courses) == 0 {
+		msg := tgbotapi.NewMessage(chatID, "Вы пока не состоите ни в одном курсе. Чтобы вступить, откройте ссылку-приглашение от преподавателя.")
+		msg.ReplyMarkup = keyboard
+		send(bot, msg)
+		return
+	}
+
+	var lines []string
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, c := range courses {
+		lines = append(lines, fmt.Sprintf("• %s — %s", c.Name, courseRoleNames[c.Role]))
+		row := tgbotapi.NewInlineKeyboardRow()
+		if c.Role == courseRoleAdmin {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("Приглашение в «"+c.Name+"»", fmt.Sprintf("course_link:%d", c.ID)))
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("Покинуть «"+c.Name+"»", fmt.Sprintf("course_leave:%d", c.ID)))
+		buttons = append(buttons, row)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Ваши курсы:\n"+strings.Join(lines, "\n"))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	send(bot, msg)
+	msg = tgbotapi.NewMessage(chatID, "Чтобы вступить в другой курс, откройте ссылку-приглашение.")
+	msg.ReplyMarkup = keyboard
+	send(bot, msg)
+}
+
+func handleCoursesMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	switch message.Text {
+	case "Создать курс":
+		userStates[chatID] = "course_create"
+		msg := tgbotapi.NewMessage(chatID, "Введите название курса или группы, например: ИУ7-31Б, ОС")
+		msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+			tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+		)
+		send(bot, msg)
+	case "Назад в главное меню":
+		delete(userStates, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+	default:
+		send(bot, tgbotapi.NewMessage(chatID, "Неверная команда. Используйте меню."))
+	}
+}
+
+func handleCourseCreation(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	if message.Text == "Назад в главное меню" {
+		showCoursesMenu(bot, chatID)
+		return
+	}
+
+	name, err := validateQueueName(message.Text)
+	var c Course
+	if err == nil {
+		c, err = createCourse(name, chatID)
+	}
+	if err != nil {
+		if !isQueueNameError(err) && !errors.Is(err, errCourseNameTaken) {
+			log.Printf("Ошибка создания курса: %v", err)
+			err = errors.New("Ошибка при создании курса.")
+		}
+		send(bot, tgbotapi.NewMessage(chatID, err.Error()+"\nВведите другое название:"))
+		return
+	}
+
+	delete(userStates, chatID)
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Курс «%s» создан. Отправьте студентам ссылку-приглашение:\n%s", c.Name, courseInviteLink(bot, c)))
+	msg.ReplyMarkup = mainMenu()
+	send(bot, msg)
+}
+
+// Кнопки в списке курсов
+func handleCourseCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, action string, courseID int) string {
+	userID := callbackQuery.From.ID
+
+	switch action {
+	case "course_link":
+		admin, err := isCourseAdmin(userID, courseID)
+		if err != nil || !admin {
+			return "Ссылку может получить только администратор курса."
+		}
+		var c Course
+		if err := db.QueryRow("SELECT name, invite_code FROM courses WHERE id = ?", courseID).Scan(&c.Name, &c.InviteCode); err != nil {
+			log.Printf("Ошибка загрузки курса: %v", err)
+			return "Ошибка при загрузке курса."
+		}
+		send(bot, tgbotapi.NewMessage(userID, fmt.Sprintf("Приглашение в курс «%s»:\n%s", c.Name, courseInviteLink(bot, c))))
+		return "Ссылка отправлена."
+
+	case "course_leave":
+		var admins int
+		err := db.QueryRow("SELECT COUNT(*) FROM course_members WHERE course_id = ? AND role = ? AND user_id != ?",
+			courseID, courseRoleAdmin, userID).Scan(&admins)
+		if err != nil {
+			log.Printf("Ошибка выхода из курса: %v", err)
+			return "Ошибка при выходе из курса."
+		}
+		if admin, _ := isCourseAdmin(userID, courseID); admin && admins == 0 {
+			return "Вы единственный администратор курса и не можете его покинуть."
+		}
+		if _, err := db.Exec("DELETE FROM course_members WHERE course_id = ? AND user_id = ?", courseID, userID); err != nil {
+			log.Printf("Ошибка выхода из курса: %v", err)
+			return "Ошибка при выходе из курса."
+		}
+		return "Вы покинули курс."
+	}
+	return ""
+}
+
+// Выбор курса для новой очереди
+func askQueueCourse(bot *tgbotapi.BotAPI, chatID int64, name string, courses []Course) {
+	userStates[chatID] = "creating_queue_course"
+	pendingQueueNames[chatID] = name
+
+	var buttons [][]tgbotapi.KeyboardButton
+	for _, c := range courses {
+		buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(c.Name)))
+	}
+	buttons = append(buttons,
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Без курса")),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+	)
+	msg := tgbotapi.NewMessage(chatID, "К какому курсу относится очередь? Очередь без курса видят все.")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
+	send(bot, msg)
+}
+
+func handleQueueCourseSelection(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	name := pendingQueueNames[chatID]
+
+	if message.Text == "Назад в главное меню" {
+		delete(userStates, chatID)
+		delete(pendingQueueNames, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	courses, err := adminCourses(chatID)
+	if err != nil {
+		log.Printf("Ошибка загрузки курсов: %v", err)
+	}
+	courseID := -1
+	if message.Text == "Без курса" {
+		courseID = 0
+	}
+	for _, c := range courses {
+		if c.Name == message.Text {
+			courseID = c.ID
+		}
+	}
+	if courseID < 0 {
+		send(bot, tgbotapi.NewMessage(chatID, "Выберите курс на клавиатуре."))
+		return
+	}
+
+	delete(userStates, chatID)
+	delete(pendingQueueNames, chatID)
+	createQueue(bot, chatID, name, courseID)
+}
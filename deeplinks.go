@@ -0,0 +1,288 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Что открывает ссылка
+const (
+	tokenKindQueue  = "queue"        // запись в очередь
+	tokenKindCourse = "course"       // вступление в курс
+	tokenKindAdmin  = "course_admin" // права администратора курса
+	tokenKindGroup  = "group"        // привязка очереди к группе
+)
+
+var (
+	errTokenInvalid = errors.New("ссылка недействительна")
+	errTokenExpired = errors.New("срок действия ссылки истёк")
+	errTokenUsed    = errors.New("ссылка уже использована")
+)
+
+// Варианты срока действия ссылки: сколько раз и сколько времени
+var inviteLimits = []struct {
+	button  string
+	maxUses int
+	ttl     time.Duration
+}{
+	{"Бессрочная", 0, 0},
+	{"Одноразовая", 1, 0},
+	{"На сутки", 0, 24 * time.Hour},
+	{"На неделю", 0, 7 * 24 * time.Hour},
+}
+
+type inviteDraft struct {
+	kind     string
+	targetID int
+}
+
+var inviteDrafts = make(map[int64]inviteDraft) // userID -> выбранный тип ссылки
+
+// Создание токена для ссылки t.me/<бот>?start=<токен>.
+// maxUses 0 — без ограничения, ttl 0 — бессрочно.
+func createInviteToken(kind string, targetID int, createdBy int64, maxUses int, ttl time.Duration) (string, error) {
+	token, err := newInviteCode()
+	if err != nil {
+		return "", err
+	}
+	var expiresAt sql.NullTime
+	if ttl > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(ttl).UTC(), Valid: true}
+	}
+	_, err = db.Exec(`INSERT INTO invite_tokens (token, kind, target_id, created_by, created_at, expires_at, max_uses)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`, token, kind, targetID, createdBy, time.Now().UTC(), expiresAt, maxUses)
+	return token, err
+}
+
+// Проверка токена без использования: вид, срок и число использований.
+// Токены других видов, чем kinds, считаются недействительными.
+func checkInviteToken(q queryer, token string, kinds ...string) (kind string, targetID int, err error) {
+	var expiresAt sql.NullTime
+	var maxUses, uses int
+	err = q.QueryRow("SELECT kind, target_id, expires_at, max_uses, uses FROM invite_tokens WHERE token = ?", token).
+		Scan(&kind, &targetID, &expiresAt, &maxUses, &uses)
+	if err == sql.ErrNoRows || err == nil && !slices.Contains(kinds, kind) {
+		return "", 0, errTokenInvalid
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if expiresAt.Valid && time.Now().After(expiresAt.Time) {
+		return "", 0, errTokenExpired
+	}
+	if maxUses > 0 && uses >= maxUses {
+		return "", 0, errTokenUsed
+	}
+	return kind, targetID, nil
+}
+
+// Использование токена в транзакции tx. Недействительный токен не расходуется.
+func useInviteToken(tx *sql.Tx, token string, kinds ...string) (kind string, targetID int, err error) {
+	if kind, targetID, err = checkInviteToken(tx, token, kinds...); err != nil {
+		return "", 0, err
+	}
+	if _, err := tx.Exec("UPDATE invite_tokens SET uses = uses + 1 WHERE token = ?", token); err != nil {
+		return "", 0, err
+	}
+	return kind, targetID, nil
+}
+
+// Использование токена: проверка срока и числа использований.
+// Токены других видов, чем kinds, считаются недействительными и не расходуются.
+func redeemInviteToken(token string, kinds ...string) (kind string, targetID int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback()
+
+	if kind, targetID, err = useInviteToken(tx, token, kinds...); err != nil {
+		return "", 0, err
+	}
+	return kind, targetID, tx.Commit()
+}
+
+func inviteLink(bot *tgbotapi.BotAPI, token string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", bot.Self.UserName, token)
+}
+
+// Обработка /start с параметром из ссылки
+func handleStartPayload(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	payload := message.CommandArguments()
+
+	// Постоянные приглашения курсов
+	if code, ok := strings.CutPrefix(payload, "course_"); ok {
+		joinCourseByInvite(bot, chatID, code)
+		return
+	}
+	if !config.Features.Invites {
+		msg := tgbotapi.NewMessage(chatID, "Ссылки-приглашения отключены. Выберите действие:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	kind, targetID, err := redeemInviteToken(payload, tokenKindQueue, tokenKindCourse, tokenKindAdmin)
+	if err != nil {
+		if !errors.Is(err, errTokenInvalid) && !errors.Is(err, errTokenExpired) && !errors.Is(err, errTokenUsed) {
+			log.Printf("Ошибка проверки ссылки: %v", err)
+			err = errors.New("ошибка при проверке ссылки")
+		}
+		msg := tgbotapi.NewMessage(chatID, "Не получилось: "+err.Error()+". Выберите действие:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	switch kind {
+	case tokenKindQueue:
+		showQueueJoinPrompt(bot, chatID, targetID)
+	case tokenKindCourse, tokenKindAdmin:
+		role := courseRoleStudent
+		if kind == tokenKindAdmin {
+			role = courseRoleAdmin
+		}
+		var name string
+		err := db.QueryRow("SELECT name FROM courses WHERE id = ?", targetID).Scan(&name)
+		if err == nil {
+			err = addCourseMember(db, targetID, chatID, role)
+		}
+		text := fmt.Sprintf("Вы в курсе «%s». Теперь в списке видны его очереди.", name)
+		if role == courseRoleAdmin {
+			text = fmt.Sprintf("Вы теперь администратор курса «%s».", name)
+		}
+		if err != nil {
+			log.Printf("Ошибка вступления в курс: %v", err)
+			text = "Ошибка при вступлении в курс."
+		}
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+	}
+}
+
+// Очередь из ссылки: описание и кнопка записи.
+// Ссылка на очередь курса заодно добавляет в курс, чтобы очередь была видна.
+func showQueueJoinPrompt(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	q, err := loadQueue(queueID)
+	if err == nil && q.CourseID != 0 {
+		err = addCourseMember(db, q.CourseID, chatID, courseRoleStudent)
+	}
+	if err != nil {
+		log.Printf("Ошибка открытия очереди по ссылке: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Эта очередь больше недоступна.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, formatQueueInfo(q)+"\nЗаписаться в эту очередь?")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Записаться", fmt.Sprintf("join:%d", queueID)),
+	))
+	send(bot, msg)
+}
+
+// Генератор ссылок из меню администратора очереди
+func startInviteGenerator(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при загрузке очереди.")
+		return
+	}
+
+	buttons := [][]tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Запись в очередь"))}
+	if q.CourseID != 0 {
+		buttons = append(buttons,
+			tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Вступление в курс")),
+			tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Администратор курса")),
+		)
+	}
+	buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")))
+
+	userStates[chatID] = "admin_invite_kind"
+	msg := tgbotapi.NewMessage(chatID, "Что должна открывать ссылка?")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
+	send(bot, msg)
+}
+
+func handleInviteKind(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при загрузке очереди.")
+		return
+	}
+
+	var draft inviteDraft
+	switch {
+	case message.Text == "Назад в главное меню":
+		backToAdminMenu(bot, chatID, queueID, "Ссылка не создана.")
+		return
+	case message.Text == "Запись в очередь":
+		draft = inviteDraft{tokenKindQueue, queueID}
+	case message.Text == "Вступление в курс" && q.CourseID != 0:
+		draft = inviteDraft{tokenKindCourse, q.CourseID}
+	case message.Text == "Администратор курса" && q.CourseID != 0:
+		draft = inviteDraft{tokenKindAdmin, q.CourseID}
+	default:
+		send(bot, tgbotapi.NewMessage(chatID, "Выберите вариант на клавиатуре."))
+		return
+	}
+	inviteDrafts[chatID] = draft
+
+	var buttons [][]tgbotapi.KeyboardButton
+	for _, l := range inviteLimits {
+		buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(l.button)))
+	}
+	buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")))
+
+	text := "Сколько должна действовать ссылка?"
+	if draft.kind == tokenKindAdmin {
+		text += "\nСсылка даёт права администратора курса — лучше сделать её одноразовой."
+	}
+	userStates[chatID] = "admin_invite_limit"
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
+	send(bot, msg)
+}
+
+func handleInviteLimit(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+	draft, ok := inviteDrafts[chatID]
+
+	if message.Text == "Назад в главное меню" || !ok {
+		delete(inviteDrafts, chatID)
+		backToAdminMenu(bot, chatID, queueID, "Ссылка не создана.")
+		return
+	}
+
+	for _, l := range inviteLimits {
+		if message.Text != l.button {
+			continue
+		}
+		delete(inviteDrafts, chatID)
+		token, err := createInviteToken(draft.kind, draft.targetID, chatID, l.maxUses, l.ttl)
+		if err != nil {
+			log.Printf("Ошибка создания ссылки: %v", err)
+			backToAdminMenu(bot, chatID, queueID, "Ошибка при создании ссылки.")
+			return
+		}
+		backToAdminMenu(bot, chatID, queueID, fmt.Sprintf("Ссылка (%s):\n%s", strings.ToLower(l.button), inviteLink(bot, token)))
+		return
+	}
+	send(bot, tgbotapi.NewMessage(chatID, "Выберите вариант на клавиатуре."))
+}
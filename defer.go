@@ -0,0 +1,191 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	errDeferLimit = errors.New("лимит пропусков в этой очереди исчерпан")
+	errDeferLast  = errors.New("вы и так в конце очереди")
+)
+
+// Сдвиг записи назад на n мест. Возвращает новое место (с единицы).
+func deferEntry(queueID, entryID, n int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var defers, maxDefers int
+	err = tx.QueryRow(`SELECT e.defers, q.max_defers FROM queue_entries e JOIN queues q ON q.id = e.queue_id
+	WHERE e.id = ? AND e.queue_id = ?`, entryID, queueID).Scan(&defers, &maxDefers)
+	if err != nil {
+		return 0, err
+	}
+	if defers >= maxDefers {
+		return 0, errDeferLimit
+	}
+
+	counts, err := entryServedCounts(tx, queueID)
+	if err != nil {
+		return 0, err
+	}
+	current := slices.IndexFunc(counts, func(c entryServed) bool { return c.entryID == entryID })
+	if current < 0 {
+		return 0, sql.ErrNoRows
+	}
+	if current == len(counts)-1 {
+		return 0, errDeferLast
+	}
+
+	ids := make([]int, 0, len(counts))
+	for _, c := range counts {
+		if c.entryID != entryID {
+			ids = append(ids, c.entryID)
+		}
+	}
+	index := min(current+n, len(ids))
+	if err := setEntryOrder(tx, slices.Insert(ids, index, entryID)); err != nil {
+		return 0, err
+	}
+	// Пропустивший больше не считается вызванным
+	if _, err := tx.Exec("UPDATE queue_entries SET defers = defers + 1, called_at = NULL, called_by = NULL, confirmed = 0 WHERE id = ?", entryID); err != nil {
+		return 0, err
+	}
+	return index + 1, tx.Commit()
+}
+
+// Первая запись пользователя в очереди
+func findOwnEntry(queueID int, userID int64) (QueueEntry, error) {
+	return scanEntry(db.QueryRow("SELECT "+entryColumns+" FROM queue_entries WHERE queue_id = ? AND user_id = ? ORDER BY position, id LIMIT 1",
+		queueID, userID))
+}
+
+// Выбор, на сколько мест пропустить
+func startDefer(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	e, err := findOwnEntry(queueID, chatID)
+	if err == sql.ErrNoRows {
+		msg := tgbotapi.NewMessage(chatID, "Вас нет в этой очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+	q, qerr := loadQueue(queueID)
+	if err != nil || qerr != nil {
+		log.Printf("Ошибка загрузки очереди: %v %v", err, qerr)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при получении списка очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	left := q.MaxDefers - e.Defers
+	if left <= 0 {
+		msg := tgbotapi.NewMessage(chatID, "Вы больше не можете пропускать других вперёд в этой очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	userStates[chatID] = "defer_select"
+	selectedQueueID[chatID] = queueID
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("На сколько мест сдвинуться назад? Осталось пропусков: %d.", left))
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Пропустить одного")),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("На 2 места"), tgbotapi.NewKeyboardButton("На 3 места")),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+	)
+	send(bot, msg)
+}
+
+// Выбор числа мест: кнопкой или числом
+func handleDeferSelect(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	var n int
+	switch message.Text {
+	case "Назад в главное меню":
+		delete(userStates, chatID)
+		delete(selectedQueueID, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	case "Пропустить одного":
+		n = 1
+	default:
+		text := strings.TrimSuffix(strings.TrimPrefix(message.Text, "На "), " места")
+		var err error
+		if n, err = strconv.Atoi(strings.TrimSpace(text)); err != nil || n < 1 {
+			msg := tgbotapi.NewMessage(chatID, "Выберите вариант на клавиатуре или введите число мест.")
+			send(bot, msg)
+			return
+		}
+	}
+
+	delete(userStates, chatID)
+	delete(selectedQueueID, chatID)
+
+	msg := tgbotapi.NewMessage(chatID, deferOwnEntry(bot, chatID, queueID, n))
+	msg.ReplyMarkup = mainMenu()
+	send(bot, msg)
+}
+
+// Пропуск вперёд своей записи. Возвращает текст ответа пользователю.
+func deferOwnEntry(bot *tgbotapi.BotAPI, userID int64, queueID, n int) string {
+	e, err := findOwnEntry(queueID, userID)
+	if err == sql.ErrNoRows {
+		return "Вас нет в этой очереди."
+	}
+	if err != nil {
+		log.Printf("Ошибка загрузки записи: %v", err)
+		return "Ошибка при пропуске вперёд."
+	}
+
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		return "Ошибка при пропуске вперёд."
+	}
+	wasHead := entries[0].ID == e.ID
+
+	position, err := deferEntry(queueID, e.ID, n)
+	if errors.Is(err, errDeferLimit) || errors.Is(err, errDeferLast) {
+		return "Не получилось: " + err.Error() + "."
+	}
+	if err != nil {
+		log.Printf("Ошибка пропуска вперёд: %v", err)
+		return "Ошибка при пропуске вперёд."
+	}
+
+	// Если пропускал уже вызванный первый, теперь вызываем нового первого
+	// и сообщаем об этом вызвавшему
+	if wasHead && e.CalledBy != 0 {
+		if q, err := loadQueue(queueID); err == nil && q.Status == queueStatusOpen {
+			if entries, err := loadEntries(queueID); err == nil && len(entries) > 0 {
+				send(bot, tgbotapi.NewMessage(e.CalledBy, fmt.Sprintf("%s пропускает вперёд.\nВызван следующий: %s%s.",
+					entryLabel(e), entryLabel(entries[0]), entryLabsText(entries[0]))))
+				callEntry(bot, entries[0], e.CalledBy)
+			}
+		}
+	}
+
+	return fmt.Sprintf("Готово, теперь вы на %d месте.", position)
+}
+
+// Кнопка пропуска для сообщения о вызове
+func deferKeyboard(queueID int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Пропустить вперёд", fmt.Sprintf("defer:%d", queueID)),
+	))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Вызванный первый пропускает вперёд в очереди без таймаута вызова:
+// вызывается следующий, а преподаватель узнаёт об этом
+func TestDeferCalledHeadWithoutTimeout(t *testing.T) {
+	setupTestDB(t)
+	bot, fake := newTestBot(t)
+	const teacher = 100
+	q := createTestQueue(t, "Лабы", 1, 2, 3)
+	if q.CallTimeout != 0 {
+		t.Fatalf("таймаут вызова по умолчанию %d, ожидался 0", q.CallTimeout)
+	}
+
+	entries, err := loadEntries(q.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callEntry(bot, entries[0], teacher)
+
+	if answer := deferOwnEntry(bot, 1, q.ID, 1); answer != "Готово, теперь вы на 2 месте." {
+		t.Fatalf("ответ %q", answer)
+	}
+
+	entries, err = loadEntries(q.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []int64
+	for _, e := range entries {
+		order = append(order, e.UserID)
+	}
+	if !slices.Equal(order, []int64{2, 1, 3}) {
+		t.Fatalf("порядок %v, ожидался [2 1 3]", order)
+	}
+	if entries[0].CalledBy != teacher {
+		t.Errorf("новый первый не вызван: called_by = %d", entries[0].CalledBy)
+	}
+	if entries[1].CalledBy != 0 {
+		t.Errorf("пропустивший остался вызванным: called_by = %d", entries[1].CalledBy)
+	}
+
+	if texts := fake.messagesTo(2); len(texts) != 1 || !strings.Contains(texts[0], "подошла") {
+		t.Errorf("следующему отправлено %q", texts)
+	}
+	if texts := fake.messagesTo(teacher); len(texts) != 1 || !strings.Contains(texts[0], "Вызван следующий: @user2") {
+		t.Errorf("преподавателю отправлено %q", texts)
+	}
+}
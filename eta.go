@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Время на одного, пока по очереди нет истории
+	defaultServiceTime = 10 * time.Minute
+	// Сколько последних приёмов учитывать
+	serviceSample = 20
+	// Промежутки длиннее считаются перерывом между занятиями
+	maxServiceGap = time.Hour
+)
+
+// Среднее время приёма одного человека: промежутки между нажатиями «Следующий»
+func averageServiceTime(queueID int) (time.Duration, error) {
+	rows, err := db.Query("SELECT served_at FROM served_entries WHERE queue_id = ? ORDER BY served_at DESC LIMIT ?",
+		queueID, serviceSample+1)
+	if err != nil {
+		return defaultServiceTime, err
+	}
+	defer rows.Close()
+
+	var total time.Duration
+	var count int
+	var prev time.Time
+	for rows.Next() {
+		var servedAt time.Time
+		if err := rows.Scan(&servedAt); err != nil {
+			return defaultServiceTime, err
+		}
+		if !prev.IsZero() {
+			if gap := prev.Sub(servedAt); gap > 0 && gap <= maxServiceGap {
+				total += gap
+				count++
+			}
+		}
+		prev = servedAt
+	}
+	if err := rows.Err(); err != nil || count == 0 {
+		return defaultServiceTime, err
+	}
+	return total / time.Duration(count), nil
+}
+
+// Ожидание для места index (с нуля): первого уже принимают
+func waitForPosition(avg time.Duration, index int) time.Duration {
+	return avg * time.Duration(index)
+}
+
+func formatWait(d time.Duration) string {
+	minutes := int((d + time.Minute/2) / time.Minute)
+	switch {
+	case d == 0:
+		return "сейчас"
+	case minutes < 1:
+		return "меньше минуты"
+	case minutes < 60:
+		return fmt.Sprintf("~%d мин", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("~%d ч", minutes/60)
+	}
+	return fmt.Sprintf("~%d ч %d мин", minutes/60, minutes%60)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var exportHeader = []string{"ФИО", "Username", "Статус", "Записался", "Принят", "Лаба", "Результат", "Комментарий"}
+
+// Вариант выгрузки в меню «Экспорт»
+type exportOption struct {
+	button string
+	xlsx   bool
+	today  bool
+}
+
+var exportButtons = []exportOption{
+	{"XLSX за сегодня", true, true},
+	{"CSV за сегодня", false, true},
+	{"XLSX за всё время", true, false},
+	{"CSV за всё время", false, false},
+}
+
+type exportResult struct {
+	userID   int64
+	username string
+	lab      int
+	outcome  string
+	comment  string
+	gradedAt time.Time
+	exported bool
+}
+
+func startExport(bot *tgbotapi.BotAPI, chatID int64) {
+	userStates[chatID] = "admin_export"
+
+	var buttons [][]tgbotapi.KeyboardButton
+	for i := 0; i < len(exportButtons); i += 2 {
+		buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(exportButtons[i].button),
+			tgbotapi.NewKeyboardButton(exportButtons[i+1].button),
+		))
+	}
+	buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")))
+
+	msg := tgbotapi.NewMessage(chatID, "Выгрузка принятых и ожидающих с результатами. В каком формате и за какой период?")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
+	send(bot, msg)
+}
+
+func handleExport(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	if message.Text == "Назад в главное меню" {
+		backToAdminMenu(bot, chatID, queueID, "Выберите действие:")
+		return
+	}
+	i := slices.IndexFunc(exportButtons, func(b exportOption) bool { return b.button == message.Text })
+	if i < 0 {
+		send(bot, tgbotapi.NewMessage(chatID, "Выберите вариант на клавиатуре."))
+		return
+	}
+	choice := exportButtons[i]
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при загрузке очереди.")
+		return
+	}
+
+	var since time.Time
+	if choice.today {
+		now := time.Now().In(location)
+		since = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	}
+	rows, err := queueExportRows(q, since)
+	if err != nil {
+		log.Printf("Ошибка выгрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при выгрузке.")
+		return
+	}
+
+	var data []byte
+	ext := "csv"
+	if choice.xlsx {
+		ext = "xlsx"
+		data, err = writeXLSX("Очередь", rows)
+	} else {
+		data, err = encodeCSV(rows)
+	}
+	if err != nil {
+		log.Printf("Ошибка выгрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при выгрузке.")
+		return
+	}
+
+	fileName := fmt.Sprintf("%s-%s.%s", strings.NewReplacer("/", "-", "\\", "-").Replace(q.Name), time.Now().In(location).Format("2006-01-02"), ext)
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: data})
+	doc.Caption = fmt.Sprintf("Очередь \"%s\": строк в выгрузке — %d.", q.Name, len(rows)-1)
+	if _, err := send(bot, doc); err != nil {
+		backToAdminMenu(bot, chatID, queueID, "Не удалось отправить файл.")
+		return
+	}
+	backToAdminMenu(bot, chatID, queueID, "Выгрузка готова.")
+}
+
+// Таблица выгрузки: по строке на каждую принятую лабу (с результатом,
+// если его поставили) и на каждого, кто ещё ждёт. since — начало периода,
+// нулевое — за всё время.
+func queueExportRows(q Queue, since time.Time) ([][]string, error) {
+	names, err := exportNames(q)
+	if err != nil {
+		return nil, err
+	}
+	results, err := loadExportResults(q.ID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{exportHeader}
+	served, err := db.Query(`SELECT COALESCE(user_id, 0), COALESCE(username, ''), joined_at, served_at, labs
+	FROM served_entries WHERE queue_id = ? AND served_at >= ? ORDER BY served_at, id`, q.ID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer served.Close()
+	for served.Next() {
+		var e QueueEntry
+		var joinedAt, servedAt sql.NullTime
+		var labs string
+		if err := served.Scan(&e.UserID, &e.Username, &joinedAt, &servedAt, &labs); err != nil {
+			return nil, err
+		}
+		entryLabs, _ := parseLabList(labs)
+		if len(entryLabs) == 0 {
+			entryLabs = []int{0}
+		}
+		for _, lab := range entryLabs {
+			row := []string{names[e.UserID], e.Username, "принят", exportTime(joinedAt), exportTime(servedAt), "", "", ""}
+			if lab != 0 {
+				row[5] = strconv.Itoa(lab)
+			}
+			if r := takeExportResult(results, e, lab, servedAt.Time); r != nil {
+				row[6], row[7] = resultNames[r.outcome], r.comment
+			}
+			rows = append(rows, row)
+		}
+	}
+	if err := served.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := loadEntries(q.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		labs := make([]string, len(e.Labs))
+		for j, lab := range e.Labs {
+			labs[j] = strconv.Itoa(lab)
+		}
+		rows = append(rows, []string{names[e.UserID], e.Username, fmt.Sprintf("в очереди, место %d", i+1),
+			exportTime(sql.NullTime{Time: e.JoinedAt, Valid: !e.JoinedAt.IsZero()}), "", strings.Join(labs, ", "), "", ""})
+	}
+	return rows, nil
+}
+
+// ФИО участников: из списка группы, иначе из профиля Telegram
+func exportNames(q Queue) (map[int64]string, error) {
+	names := make(map[int64]string)
+	rows, err := db.Query(`SELECT user_id, TRIM(COALESCE(first_name, '') || ' ' || COALESCE(last_name, '')) FROM users
+	WHERE user_id IN (SELECT user_id FROM queue_entries WHERE queue_id = ? UNION SELECT user_id FROM served_entries WHERE queue_id = ?)`, q.ID, q.ID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var userID int64
+		var name string
+		if err := rows.Scan(&userID, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names[userID] = name
+	}
+	rows.Close()
+
+	roster, err := loadRoster(q)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range roster {
+		if e.UserID != 0 {
+			names[e.UserID] = e.FullName
+		}
+	}
+	delete(names, 0)
+	return names, nil
+}
+
+func loadExportResults(queueID int, since time.Time) ([]*exportResult, error) {
+	rows, err := db.Query(`SELECT COALESCE(user_id, 0), COALESCE(username, ''), lab, outcome, comment, graded_at
+	FROM lab_results WHERE queue_id = ? AND graded_at >= ? ORDER BY graded_at, id`, queueID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*exportResult
+	for rows.Next() {
+		r := &exportResult{}
+		var gradedAt sql.NullTime
+		if err := rows.Scan(&r.userID, &r.username, &r.lab, &r.outcome, &r.comment, &gradedAt); err != nil {
+			return nil, err
+		}
+		r.gradedAt = gradedAt.Time
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// Первый ещё не выгруженный результат этой лабы, поставленный после приёма
+func takeExportResult(results []*exportResult, e QueueEntry, lab int, servedAt time.Time) *exportResult {
+	for _, r := range results {
+		sameUser := r.userID == e.UserID && (e.UserID != 0 || strings.EqualFold(r.username, e.Username))
+		if !r.exported && sameUser && r.lab == lab && !r.gradedAt.Before(servedAt) {
+			r.exported = true
+			return r
+		}
+	}
+	return nil
+}
+
+func exportTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return formatTime(t.Time)
+}
+
+// CSV для Excel: разделитель «;», BOM, чтобы кириллица открылась без вопросов,
+// и защита от формул в ячейках
+func encodeCSV(rows [][]string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("\xef\xbb\xbf")
+	w := csv.NewWriter(&b)
+	w.Comma = ';'
+	for _, row := range rows {
+		safe := make([]string, len(row))
+		for i, cell := range row {
+			if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+				cell = "'" + cell
+			}
+			safe[i] = cell
+		}
+		if err := w.Write(safe); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
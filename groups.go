@@ -0,0 +1,347 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Ссылка на привязку действует сутки и один раз
+const groupLinkTTL = 24 * time.Hour
+
+const groupHelpText = "Команды бота в группе:\n" +
+	"/queue — показать очередь\n" +
+	"/join — записаться\n" +
+	"/leave — выйти из очереди\n" +
+	"Если к группе привязано несколько очередей, укажите название: /join Лабы по физике"
+
+// Сообщения из групп: только команды, остальное бот не читает
+func handleGroupMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	// Группа стала супергруппой — у неё новый ID
+	if message.MigrateToChatID != 0 {
+		_, err := db.Exec("UPDATE queues SET group_chat_id = ? WHERE group_chat_id = ?", message.MigrateToChatID, message.Chat.ID)
+		if err != nil {
+			log.Printf("Ошибка переноса привязки группы: %v", err)
+		}
+		return
+	}
+
+	if !message.IsCommand() || message.From == nil {
+		return
+	}
+	// Команда, адресованная другому боту
+	if _, to, ok := strings.Cut(message.CommandWithAt(), "@"); ok && !strings.EqualFold(to, bot.Self.UserName) {
+		return
+	}
+	rememberUser(message.From)
+
+	switch message.Command() {
+	case "start":
+		if message.CommandArguments() != "" {
+			bindGroup(bot, message)
+			return
+		}
+		replyInGroup(bot, message, groupHelpText)
+	case "help":
+		replyInGroup(bot, message, groupHelpText)
+	case "queue":
+		showGroupQueues(bot, message)
+	case "join":
+		joinFromGroup(bot, message)
+	case "leave":
+		leaveFromGroup(bot, message)
+	}
+}
+
+func replyInGroup(bot *tgbotapi.BotAPI, message *tgbotapi.Message, text string) {
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyToMessageID = message.MessageID
+	send(bot, msg)
+}
+
+// Объявление в группе очереди. Отправка идёт через outbox.
+func announceToGroup(ex execer, q Queue, text string) {
+	if q.GroupChatID == 0 || !config.Features.Groups {
+		return
+	}
+	if err := enqueueMessage(ex, q.GroupChatID, text); err != nil {
+		log.Printf("Ошибка объявления в группе очереди %d: %v", q.ID, err)
+	}
+}
+
+// Состоит ли пользователь в группе, к которой привязана очередь
+func isGroupMember(bot *tgbotapi.BotAPI, groupChatID, userID int64) (bool, error) {
+	member, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: groupChatID, UserID: userID},
+	})
+	if err != nil {
+		return false, err
+	}
+	switch member.Status {
+	case "creator", "administrator", "member":
+		return true, nil
+	case "restricted":
+		return member.IsMember, nil
+	}
+	return false, nil
+}
+
+// Привязка по ссылке t.me/<бот>?startgroup=<токен>: Telegram добавляет бота
+// в выбранную группу и присылает туда /start с токеном.
+// Ссылка расходуется, только если её открыл администратор очереди.
+func bindGroup(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	token := message.CommandArguments()
+	_, queueID, err := checkInviteToken(db, token, tokenKindGroup)
+	if err != nil {
+		replyBindError(bot, message, err)
+		return
+	}
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		replyInGroup(bot, message, "Эта очередь больше недоступна.")
+		return
+	}
+	if ok, err := canManageQueue(message.From.ID, q); err != nil || !ok {
+		replyInGroup(bot, message, "Привязать очередь к группе может только её администратор.")
+		return
+	}
+
+	// Ссылку могли использовать, пока шла проверка прав, поэтому
+	// расходуем её и привязываем очередь одной транзакцией
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Ошибка привязки очереди к группе: %v", err)
+		replyInGroup(bot, message, "Ошибка при привязке очереди.")
+		return
+	}
+	defer tx.Rollback()
+	if _, _, err := useInviteToken(tx, token, tokenKindGroup); err != nil {
+		replyBindError(bot, message, err)
+		return
+	}
+	_, err = tx.Exec("UPDATE queues SET group_chat_id = ?, group_title = ? WHERE id = ?", message.Chat.ID, message.Chat.Title, queueID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Ошибка привязки очереди к группе: %v", err)
+		replyInGroup(bot, message, "Ошибка при привязке очереди.")
+		return
+	}
+	replyInGroup(bot, message, fmt.Sprintf("Очередь \"%s\" привязана к этой группе. Записываться в неё могут только участники группы.\n\n%s", q.Name, groupHelpText))
+}
+
+// Ответ на недействительную ссылку привязки
+func replyBindError(bot *tgbotapi.BotAPI, message *tgbotapi.Message, err error) {
+	if !errors.Is(err, errTokenInvalid) && !errors.Is(err, errTokenExpired) && !errors.Is(err, errTokenUsed) {
+		log.Printf("Ошибка проверки ссылки: %v", err)
+		err = errors.New("ошибка при проверке ссылки")
+	}
+	replyInGroup(bot, message, "Не получилось привязать очередь: "+err.Error()+".")
+}
+
+// Очереди, привязанные к группе (кроме архивных)
+func groupQueues(groupChatID int64) ([]Queue, error) {
+	rows, err := db.Query("SELECT "+queueColumns+" FROM queues WHERE group_chat_id = ? AND status != ? ORDER BY id", groupChatID, queueStatusArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queues []Queue
+	for rows.Next() {
+		q, err := scanQueue(rows)
+		if err != nil {
+			return nil, err
+		}
+		queues = append(queues, q)
+	}
+	return queues, rows.Err()
+}
+
+// Выбор очереди группы по аргументу команды. Если очередь не выбрана,
+// возвращается текст для ответа.
+func pickGroupQueue(message *tgbotapi.Message) (Queue, string) {
+	queues, err := groupQueues(message.Chat.ID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очередей группы: %v", err)
+		return Queue{}, "Ошибка при загрузке очередей."
+	}
+	if len(queues) == 0 {
+		return Queue{}, "К этой группе не привязано ни одной очереди."
+	}
+
+	name := queueNameKey(message.CommandArguments())
+	if name == "" && len(queues) == 1 {
+		return queues[0], ""
+	}
+	var names []string
+	for _, q := range queues {
+		if queueNameKey(q.Name) == name {
+			return q, ""
+		}
+		names = append(names, "• "+q.Name)
+	}
+	return Queue{}, fmt.Sprintf("Укажите очередь после команды: /%s <название>.\nОчереди группы:\n%s", message.Command(), strings.Join(names, "\n"))
+}
+
+func showGroupQueues(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	queues, err := groupQueues(message.Chat.ID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очередей группы: %v", err)
+		replyInGroup(bot, message, "Ошибка при загрузке очередей.")
+		return
+	}
+	if len(queues) == 0 {
+		replyInGroup(bot, message, "К этой группе не привязано ни одной очереди.")
+		return
+	}
+
+	var parts []string
+	for _, q := range queues {
+		entries, err := loadEntries(q.ID)
+		if err != nil {
+			log.Printf("Ошибка загрузки очереди: %v", err)
+			replyInGroup(bot, message, "Ошибка при загрузке очереди.")
+			return
+		}
+		parts = append(parts, fmt.Sprintf("Очередь \"%s\":\n%s", q.Name, formatQueueEntries(q, entries)))
+	}
+	replyInGroup(bot, message, strings.Join(parts, "\n\n"))
+}
+
+func joinFromGroup(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	q, refusal := pickGroupQueue(message)
+	if refusal != "" {
+		replyInGroup(bot, message, refusal)
+		return
+	}
+	user := message.From
+
+	if q.Status != queueStatusOpen && !allowsPreRegistration(q) {
+		replyInGroup(bot, message, queueClosedText(q))
+		return
+	}
+	refusal, err := entryRefusal(bot, q, user.ID)
+	if err != nil {
+		log.Printf("Ошибка проверки очереди: %v", err)
+		refusal = "Ошибка при добавлении в очередь."
+	}
+	if refusal != "" {
+		replyInGroup(bot, message, refusal)
+		return
+	}
+	if allowed, err := rosterAllows(q, user.ID, user.UserName); err != nil || !allowed {
+		if err != nil {
+			log.Printf("Ошибка проверки списка группы: %v", err)
+		}
+		replyInGroup(bot, message, fmt.Sprintf("Вас нет в списке группы очереди \"%s\" — запишитесь в личном чате с ботом, там можно найти себя в списке или отправить заявку: https://t.me/%s", q.Name, bot.Self.UserName))
+		return
+	}
+	if len(q.Labs) > 0 {
+		replyInGroup(bot, message, fmt.Sprintf("В очереди \"%s\" нужно выбрать лабы — запишитесь в личном чате с ботом: https://t.me/%s", q.Name, bot.Self.UserName))
+		return
+	}
+
+	if err := insertQueueEntry(q, user.ID, user.UserName, nil); err != nil {
+		log.Printf("Ошибка добавления в очередь: %v", err)
+		replyInGroup(bot, message, "Ошибка при добавлении в очередь.")
+		return
+	}
+	text := fmt.Sprintf("%s теперь в очереди \"%s\".", entryLabel(QueueEntry{UserID: user.ID, Username: user.UserName}), q.Name)
+	if place, wait := joinedPlace(q, user.ID); place > 0 {
+		text += fmt.Sprintf(" Место: %d.", place)
+		if wait != "" {
+			text += " Ожидание: " + wait + "."
+		}
+	}
+	replyInGroup(bot, message, text)
+}
+
+func leaveFromGroup(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	q, refusal := pickGroupQueue(message)
+	if refusal != "" {
+		replyInGroup(bot, message, refusal)
+		return
+	}
+
+	found, err := removeFromQueue(bot, q.ID, message.From.ID)
+	switch {
+	case err != nil:
+		log.Printf("Ошибка выхода из очереди: %v", err)
+		replyInGroup(bot, message, "Ошибка при выходе из очереди.")
+	case !found:
+		replyInGroup(bot, message, fmt.Sprintf("Вас нет в очереди \"%s\".", q.Name))
+	default:
+		replyInGroup(bot, message, fmt.Sprintf("%s выходит из очереди \"%s\".", entryLabel(QueueEntry{UserID: message.From.ID, Username: message.From.UserName}), q.Name))
+	}
+}
+
+// Кнопка «Группа очереди» в меню администратора
+func startGroupBinding(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при загрузке очереди.")
+		return
+	}
+
+	token, err := createInviteToken(tokenKindGroup, queueID, chatID, 1, groupLinkTTL)
+	if err != nil {
+		log.Printf("Ошибка создания ссылки: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при создании ссылки.")
+		return
+	}
+	link := fmt.Sprintf("https://t.me/%s?startgroup=%s", bot.Self.UserName, token)
+
+	text := "Очередь не привязана к группе."
+	if q.GroupChatID != 0 {
+		text = fmt.Sprintf("Очередь привязана к группе «%s».", q.GroupTitle)
+	}
+	text += "\nЧтобы привязать её к группе, откройте ссылку и выберите группу (ссылка действует сутки):\n" + link +
+		"\nПосле привязки записываться смогут только участники группы, а события очереди будут объявляться в ней."
+	msg := tgbotapi.NewMessage(chatID, text)
+	if q.GroupChatID != 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Отвязать от группы", fmt.Sprintf("group_unbind:%d", queueID)),
+		))
+	}
+	send(bot, msg)
+}
+
+// Отвязка очереди от группы по кнопке
+func unbindGroup(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, queueID int) string {
+	q, err := loadQueue(queueID)
+	if err == sql.ErrNoRows {
+		return "Очередь не найдена."
+	}
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		return "Ошибка при загрузке очереди."
+	}
+	if ok, err := canManageQueue(callbackQuery.From.ID, q); err != nil || !ok {
+		return "Недостаточно прав."
+	}
+	if q.GroupChatID == 0 {
+		return "Очередь уже не привязана к группе."
+	}
+
+	if _, err := db.Exec("UPDATE queues SET group_chat_id = NULL, group_title = '' WHERE id = ?", queueID); err != nil {
+		log.Printf("Ошибка отвязки очереди от группы: %v", err)
+		return "Ошибка при отвязке."
+	}
+	announceToGroup(db, q, fmt.Sprintf("Очередь \"%s\" больше не привязана к этой группе.", q.Name))
+
+	if callbackQuery.Message != nil {
+		send(bot, tgbotapi.NewEditMessageText(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID,
+			fmt.Sprintf("Очередь отвязана от группы «%s».", q.GroupTitle)))
+	}
+	return "Очередь отвязана."
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func groupStartMessage(chatID, userID int64, token string) *tgbotapi.Message {
+	text := "/start " + token
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "supergroup", Title: "ИВТ-21"},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/start")}},
+	}
+}
+
+// Одноразовую ссылку привязки не может израсходовать участник группы без прав
+func TestBindGroupKeepsTokenForAdmin(t *testing.T) {
+	setupTestDB(t)
+	bot, fake := newTestBot(t)
+	const admin, student, group = 10, 20, -1000
+	c, err := createCourse("Курс", admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := createTestQueue(t, "Лабы")
+	if _, err := db.Exec("UPDATE queues SET course_id = ? WHERE id = ?", c.ID, q.ID); err != nil {
+		t.Fatal(err)
+	}
+	token, err := createInviteToken(tokenKindGroup, q.ID, admin, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bindGroup(bot, groupStartMessage(group, student, token))
+	if texts := fake.messagesTo(group); len(texts) != 1 || !strings.Contains(texts[0], "только её администратор") {
+		t.Fatalf("в группу отправлено %q", texts)
+	}
+	if _, _, err := checkInviteToken(db, token, tokenKindGroup); err != nil {
+		t.Fatalf("ссылка израсходована без прав: %v", err)
+	}
+
+	bindGroup(bot, groupStartMessage(group, admin, token))
+	if q, err = loadQueue(q.ID); err != nil {
+		t.Fatal(err)
+	}
+	if q.GroupChatID != group {
+		t.Errorf("очередь не привязана: group_chat_id = %d", q.GroupChatID)
+	}
+	if _, _, err := checkInviteToken(db, token, tokenKindGroup); err != errTokenUsed {
+		t.Errorf("после привязки ожидалась errTokenUsed, получено %v", err)
+	}
+}
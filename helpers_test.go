@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Отправленное ботом сообщение
+type sentMessage struct {
+	chatID int64
+	text   string
+}
+
+// Поддельный Telegram API: запоминает отправленные сообщения
+type fakeTelegram struct {
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+func (f *fakeTelegram) messages() []sentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]sentMessage(nil), f.sent...)
+}
+
+// Сообщения, отправленные в чат
+func (f *fakeTelegram) messagesTo(chatID int64) []string {
+	var texts []string
+	for _, m := range f.messages() {
+		if m.chatID == chatID {
+			texts = append(texts, m.text)
+		}
+	}
+	return texts
+}
+
+func newTestBot(t *testing.T) (*tgbotapi.BotAPI, *fakeTelegram) {
+	t.Helper()
+	fake := &fakeTelegram{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if path.Base(r.URL.Path) == "sendMessage" {
+			chatID, _ := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+			fake.mu.Lock()
+			fake.sent = append(fake.sent, sentMessage{chatID: chatID, text: r.PostForm.Get("text")})
+			fake.mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	// Ограничения скорости отправки в тестах только замедляют
+	prev := outbound
+	outbound = newRateLimiter(1000, 1000, 1000, 1000)
+	t.Cleanup(func() { outbound = prev })
+
+	bot := &tgbotapi.BotAPI{Token: "test", Client: srv.Client(), Self: tgbotapi.User{UserName: "test_bot"}}
+	bot.SetAPIEndpoint(srv.URL + "/bot%s/%s")
+	return bot, fake
+}
+
+// Временная база со всеми миграциями вместо глобальной db
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	d, err := openDB("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateDB(d); err != nil {
+		t.Fatal(err)
+	}
+	prev := db
+	db = d
+	t.Cleanup(func() {
+		db = prev
+		d.Close()
+	})
+}
+
+// Открытая общая очередь с участниками в указанном порядке
+func createTestQueue(t *testing.T, name string, userIDs ...int64) Queue {
+	t.Helper()
+	res, err := db.Exec("INSERT INTO queues (name, name_key, status) VALUES (?, ?, ?)", name, queueNameKey(name), queueStatusOpen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	q, err := loadQueue(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range userIDs {
+		if err := insertQueueEntry(q, userID, "user"+strconv.FormatInt(userID, 10), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return q
+}
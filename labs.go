@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Выбор лаб при записи в очередь, где задан список лаб
+func startLabSelection(bot *tgbotapi.BotAPI, chatID int64, q Queue) {
+	userStates[chatID] = "join_labs"
+	selectedQueueID[chatID] = q.ID
+
+	msg := tgbotapi.NewMessage(chatID, "Какую лабу сдаёте? Выберите номер на клавиатуре или перечислите несколько через запятую: 2, 3")
+	msg.ReplyMarkup = labsKeyboard(q.Labs)
+	send(bot, msg)
+}
+
+// Кнопки с номерами лаб, по четыре в ряд
+func labsKeyboard(labs []int) tgbotapi.ReplyKeyboardMarkup {
+	var buttons [][]tgbotapi.KeyboardButton
+	for i := 0; i < len(labs); i += 4 {
+		var row []tgbotapi.KeyboardButton
+		for _, n := range labs[i:min(i+4, len(labs))] {
+			row = append(row, tgbotapi.NewKeyboardButton(strconv.Itoa(n)))
+		}
+		buttons = append(buttons, row)
+	}
+	buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")))
+	return tgbotapi.NewReplyKeyboard(buttons...)
+}
+
+func handleJoinLabs(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	if message.Text == "Назад в главное меню" {
+		delete(userStates, chatID)
+		delete(selectedQueueID, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	q, err := loadQueue(queueID)
+	if err == nil {
+		var labs []int
+		if labs, err = parseEntryLabs(q, message.Text); err != nil {
+			msg := tgbotapi.NewMessage(chatID, "Не получилось: "+err.Error()+". Попробуйте снова:")
+			send(bot, msg)
+			return
+		}
+		delete(userStates, chatID)
+		delete(selectedQueueID, chatID)
+		addUserToQueue(bot, chatID, queueID, message.From.UserName, labs)
+		return
+	}
+
+	delete(userStates, chatID)
+	delete(selectedQueueID, chatID)
+	msg := tgbotapi.NewMessage(chatID, "Ошибка при добавлении в очередь.")
+	msg.ReplyMarkup = mainMenu()
+	send(bot, msg)
+}
+
+// Лабы, выбранные студентом: только из списка очереди
+func parseEntryLabs(q Queue, text string) ([]int, error) {
+	labs, err := parseLabList(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(labs) == 0 {
+		return nil, fmt.Errorf("нужно указать хотя бы одну лабу")
+	}
+	for _, n := range labs {
+		if !slices.Contains(q.Labs, n) {
+			return nil, fmt.Errorf("лаба %d в этой очереди не принимается, доступны: %s", n, formatLabList(q.Labs))
+		}
+	}
+	return labs, nil
+}
+
+// Подпись к записи со сдаваемыми лабами: " (лаба 2)", " (лабы 2, 3)"
+func entryLabsText(e QueueEntry) string {
+	switch len(e.Labs) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf(" (лаба %d)", e.Labs[0])
+	}
+	return " (лабы " + formatLabList(e.Labs) + ")"
+}
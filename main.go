@@ -2,16 +2,22 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+const defaultDBPath = "queues.db"
+
 var (
 	db              *sql.DB
 	userStates      = make(map[int64]string) // userID -> state
@@ -24,21 +30,68 @@ var (
 		"Создать очередь",
 		"Изменить очередь (Админ)",
 		"Назад в главное меню",
+		"Очистить очередь",
+		"Удалить очередь",
+		"Удалить пользователя из очереди",
+		"Переименовать очередь",
+		"Настройки очереди",
+		"Расписание (Админ)",
+		"Следующий",
+		"Переместить участника",
+		"Добавить участника",
+		"Приоритет участника",
+		"Поменяться местами",
+		"Пропустить вперёд",
+		"Мои лабы",
+		"Уведомления о месте",
+		"Написать всем в очереди",
+		"Мои очереди",
+		"Курсы",
+		"Ссылка-приглашение",
+		"Группа очереди",
+		"Список группы",
+		"Добавить в список",
+		"Очистить список",
+		"Экспорт",
 	}
 )
 
 func main() {
-	botToken := os.Getenv("API_KEY")
-	if botToken == "" {
-		log.Fatalf("Токен не найден!")
+	// Обслуживание базы из командной строки, без запуска бота
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		os.Exit(runAdminCLI(os.Args[2:]))
+	}
+
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ExitOnError)
+	flags := registerConfigFlags(fs)
+	fs.Parse(os.Args[1:])
+	cfg, err := loadConfig(fs, flags)
+	if err != nil {
+		log.Fatalf("Ошибка настроек: %v", err)
+	}
+	if *flags.printConfig {
+		if err := printConfig(os.Stdout, cfg); err != nil {
+			log.Fatalf("Ошибка вывода настроек: %v", err)
+		}
+		if err := cfg.validate(true); err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибки в настройках:\n%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := cfg.validate(true); err != nil {
+		log.Fatalf("Ошибки в настройках:\n%v", err)
 	}
+	config = cfg
+	r := config.RateLimits
+	outbound = newRateLimiter(r.GlobalRate, r.GlobalBurst, r.ChatRate, r.ChatBurst)
 
-	bot, err := tgbotapi.NewBotAPI(botToken)
+	bot, err := tgbotapi.NewBotAPI(config.Token)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	bot.Debug = true
+	bot.Debug = config.Debug
 	log.Printf("Бот авторизован на аккаунте %s", bot.Self.UserName)
 
 	db, err = initDB()
@@ -47,12 +100,24 @@ func main() {
 	}
 	defer db.Close()
 
+	location, err = loadLocation()
+	if err != nil {
+		log.Fatalf("Ошибка часового пояса: %v", err)
+	}
+
+	go runScheduler(bot)
+	go runNotifier(bot)
+
 	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	u.Timeout = config.PollTimeout
 	updates := bot.GetUpdatesChan(u)
 
 	for update := range updates {
-		if update.Message != nil {
+		if update.Message != nil && !update.Message.Chat.IsPrivate() {
+			if config.Features.Groups {
+				handleGroupMessage(bot, update.Message)
+			}
+		} else if update.Message != nil {
 			handleMessage(bot, update.Message)
 		} else if update.CallbackQuery != nil {
 			handleCallback(bot, update.CallbackQuery)
@@ -62,7 +127,16 @@ func main() {
 
 // Инициализация базы данных
 func initDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", "queues.db")
+	db, err := openDB(config.Database.Driver, config.Database.DSN)
+	if err != nil {
+		return db, err
+	}
+	return db, migrateDB(db)
+}
+
+// Открытие базы и создание исходных таблиц, без миграций
+func openDB(driver, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -89,14 +163,78 @@ func initDB() (*sql.DB, error) {
 // Обработка входящих сообщений
 func handleMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	userID := message.Chat.ID
+	rememberUser(message.From)
+
+	// Ссылка с параметром (приглашение в курс) работает из любого состояния
+	if message.IsCommand() && message.Command() == "start" && message.CommandArguments() != "" {
+		delete(userStates, userID)
+		handleStartPayload(bot, message)
+		return
+	}
 
 	switch userStates[userID] {
 	case "select_queue_for_action":
 		handleQueueActionSelection(bot, message)
 	case "creating_queue":
 		handleQueueCreation(bot, message) // Пользователь вводит название новой очереди
+	case "creating_queue_course":
+		handleQueueCourseSelection(bot, message)
+	case "courses_menu":
+		handleCoursesMenu(bot, message)
+	case "course_create":
+		handleCourseCreation(bot, message)
 	case "admin_delete_user":
 		deleteUserFromQueue(bot, message)
+	case "admin_rename_queue":
+		handleQueueRename(bot, message)
+	case "admin_settings":
+		handleSettingsMenu(bot, message)
+	case "admin_settings_value":
+		handleSettingsValue(bot, message)
+	case "admin_move_select":
+		handleMoveSelect(bot, message)
+	case "admin_move_action":
+		handleMoveAction(bot, message)
+	case "admin_move_position":
+		handleMovePosition(bot, message)
+	case "admin_insert_user":
+		handleInsertUser(bot, message)
+	case "admin_priority":
+		handleSetPriority(bot, message)
+	case "admin_result":
+		handleResultSelect(bot, message)
+	case "admin_result_comment":
+		handleResultComment(bot, message)
+	case "admin_export":
+		handleExport(bot, message)
+	case "roster_claim":
+		handleRosterClaim(bot, message)
+	case "roster_menu":
+		handleRosterMenu(bot, message)
+	case "roster_add":
+		handleRosterAdd(bot, message)
+	case "roster_claim_confirm":
+		handleRosterClaimConfirm(bot, message)
+	case "roster_import_confirm":
+		handleRosterImportConfirm(bot, message)
+	case "admin_invite_kind":
+		handleInviteKind(bot, message)
+	case "admin_invite_limit":
+		handleInviteLimit(bot, message)
+	case "admin_broadcast":
+		handleBroadcastMessage(bot, message)
+	case "admin_broadcast_confirm":
+		handleBroadcastConfirm(bot, message)
+	case "swap_select":
+		handleSwapSelect(bot, message)
+	case "defer_select":
+		handleDeferSelect(bot, message)
+	case "join_labs":
+		handleJoinLabs(bot, message)
+	case "templates_menu":
+		handleTemplatesMenu(bot, message)
+	case "template_create":
+		handleTemplateCreation(bot, message)
 	case "admin_mode":
 		handleAdminActions(bot, message) // Обработка администраторских действий
 	// case "admin_delete_user":
@@ -113,7 +251,7 @@ func deleteUserFromQueue(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	if !exists {
 		msg := tgbotapi.NewMessage(chatID, "Ошибка: очередь не выбрана.")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
 
@@ -122,7 +260,7 @@ func deleteUserFromQueue(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	if err != nil {
 		msg := tgbotapi.NewMessage(chatID, "Ошибка при удалении пользователя.")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
 
@@ -130,11 +268,11 @@ func deleteUserFromQueue(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	if rowsAffected == 0 {
 		msg := tgbotapi.NewMessage(chatID, "Пользователь не найден в этой очереди.")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 	} else {
 		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Пользователь \"%s\" успешно удалён из очереди.", username))
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 	}
 
 	delete(userStates, chatID) // Сброс состояния
@@ -147,18 +285,39 @@ func handleAdminActions(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	if !exists {
 		msg := tgbotapi.NewMessage(chatID, "Ошибка: очередь не выбрана.")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
 
+	keepMode := false
 	defer func() {
-		if userStates[chatID] != "admin_delete_user" {
+		if userStates[chatID] == "admin_mode" && !keepMode {
 			delete(userStates, message.Chat.ID)
 			delete(queueActions, message.Chat.ID)
 		}
 	}()
 
-	switch message.Text {
+	action := message.Text
+	if !adminButtonEnabled(action) {
+		action = ""
+	}
+	switch action {
+	case "Следующий":
+		keepMode = true
+		callNextUser(bot, chatID, queueID)
+	case "Переместить участника":
+		startMoveEntry(bot, chatID, queueID)
+	case "Добавить участника":
+		userStates[chatID] = "admin_insert_user"
+		msg := tgbotapi.NewMessage(chatID, "Введите username и место в очереди, например: @ivanov 3\nБез места — по обычным правилам очереди.")
+		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		send(bot, msg)
+	case "Приоритет участника":
+		userStates[chatID] = "admin_priority"
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите username и уровень приоритета от 0 до %d, например: @ivanov 1\n"+
+			"Участники с приоритетом идут раньше остальных, 0 — снять приоритет (участник встанет в конец).", maxPriority))
+		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		send(bot, msg)
 	case "Очистить очередь":
 		clearQueue(bot, chatID, queueID)
 	case "Удалить очередь":
@@ -166,16 +325,36 @@ func handleAdminActions(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	case "Удалить пользователя из очереди":
 		userStates[chatID] = "admin_delete_user"
 		msg := tgbotapi.NewMessage(chatID, "Введите username пользователя для удаления из очереди:")
-		bot.Send(msg)
+		send(bot, msg)
+	case "Переименовать очередь":
+		userStates[chatID] = "admin_rename_queue"
+		msg := tgbotapi.NewMessage(chatID, "Введите новое название очереди:")
+		msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+			tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+		)
+		send(bot, msg)
+	case "Настройки очереди":
+		showQueueSettings(bot, chatID, queueID)
+	case "Написать всем в очереди":
+		startBroadcast(bot, chatID)
+	case "Ссылка-приглашение":
+		startInviteGenerator(bot, chatID, queueID)
+	case "Список группы":
+		showRosterMenu(bot, chatID, queueID)
+	case "Экспорт":
+		startExport(bot, chatID)
+	case "Группа очереди":
+		keepMode = true
+		startGroupBinding(bot, chatID, queueID)
 	case "Назад в главное меню":
 		delete(userStates, chatID)
 		delete(selectedQueueID, chatID)
 		msg := tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 	default:
 		msg := tgbotapi.NewMessage(chatID, "Неверная команда. Используйте меню.")
-		bot.Send(msg)
+		send(bot, msg)
 	}
 }
 
@@ -183,13 +362,13 @@ func clearQueue(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
 	_, err := db.Exec("DELETE FROM queue_entries WHERE queue_id = ?", queueID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(chatID, "Ошибка при очистке очереди.")
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
 
 	msg := tgbotapi.NewMessage(chatID, "Очередь успешно очищена.")
 	msg.ReplyMarkup = mainMenu()
-	bot.Send(msg)
+	send(bot, msg)
 }
 
 func deleteQueue(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
@@ -198,15 +377,21 @@ func deleteQueue(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
 	if err != nil {
 		msg := tgbotapi.NewMessage(chatID, "Ошибка при удалении очереди.")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
 
 	_, err = db.Exec("DELETE FROM queue_entries WHERE queue_id = ?", queueID)
+	if err == nil {
+		_, err = db.Exec("DELETE FROM roster WHERE queue_id = ?", queueID)
+	}
+	if err == nil {
+		_, err = db.Exec("DELETE FROM join_requests WHERE queue_id = ?", queueID)
+	}
 	if err != nil {
 		msg := tgbotapi.NewMessage(chatID, "Ошибка при удалении записей из очереди.")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
 
@@ -214,7 +399,46 @@ func deleteQueue(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
 
 	msg := tgbotapi.NewMessage(chatID, "Очередь успешно удалена.")
 	msg.ReplyMarkup = mainMenu()
-	bot.Send(msg)
+	send(bot, msg)
+}
+
+// Приём первого в очереди и вызов следующего
+func callNextUser(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	served, next, err := serveNextEntry(queueID)
+	if err != nil {
+		log.Printf("Ошибка вызова следующего: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при вызове следующего.")
+		msg.ReplyMarkup = adminMenuKeyboard()
+		send(bot, msg)
+		return
+	}
+
+	text := "Очередь пуста."
+	if served != nil {
+		text = fmt.Sprintf("Принят: %s%s.", entryLabel(*served), entryLabsText(*served))
+		if next != nil {
+			text += fmt.Sprintf("\nСледующий: %s%s.", entryLabel(*next), entryLabsText(*next))
+		} else {
+			text += "\nБольше в очереди никого нет."
+		}
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = adminMenuKeyboard()
+	send(bot, msg)
+
+	if next != nil {
+		callEntry(bot, *next, chatID)
+	}
+
+	// Записываем результат сдачи принятого
+	if served != nil {
+		q, err := loadQueue(queueID)
+		if err != nil {
+			log.Printf("Ошибка загрузки очереди: %v", err)
+			return
+		}
+		startGrading(bot, chatID, q, *served)
+	}
 }
 
 // Удаление конкретного пользователя
@@ -226,7 +450,7 @@ func handleDeleteUser(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	if err != nil {
 		msg := tgbotapi.NewMessage(message.Chat.ID, "Ошибка при удалении пользователя.")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
 
@@ -234,11 +458,11 @@ func handleDeleteUser(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	if rowsAffected == 0 {
 		msg := tgbotapi.NewMessage(message.Chat.ID, "Пользователь не найден в этой очереди.")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 	} else {
 		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Пользователь \"%s\" удален из очереди.", username))
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 	}
 
 	delete(userStates, message.Chat.ID) // Сброс состояния
@@ -250,7 +474,7 @@ func showMainMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	case "/start":
 		msg := tgbotapi.NewMessage(message.Chat.ID, "Добро пожаловать! Выберите действие:")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 
 	case "Зайти в очередь":
 		userStates[message.Chat.ID] = "select_queue_for_action"
@@ -262,100 +486,134 @@ func showMainMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 		queueActions[message.Chat.ID] = "show"
 		showQueues(bot, message.Chat.ID)
 
+	case "Поменяться местами":
+		userStates[message.Chat.ID] = "select_queue_for_action"
+		queueActions[message.Chat.ID] = "swap"
+		showQueues(bot, message.Chat.ID)
+
+	case "Пропустить вперёд":
+		userStates[message.Chat.ID] = "select_queue_for_action"
+		queueActions[message.Chat.ID] = "defer"
+		showQueues(bot, message.Chat.ID)
+
+	case "Мои очереди":
+		showMyQueues(bot, message.Chat.ID)
+
+	case "Курсы":
+		showCoursesMenu(bot, message.Chat.ID)
+
+	case "Мои лабы":
+		showMyLabs(bot, message.Chat.ID)
+
+	case "Уведомления о месте":
+		toggleNotifications(bot, message.Chat.ID)
+
 	case "Создать очередь":
 		userStates[message.Chat.ID] = "creating_queue"
 		msg := tgbotapi.NewMessage(message.Chat.ID, "Введите название новой очереди:")
-		bot.Send(msg)
+		send(bot, msg)
 
 	case "Изменить очередь (Админ)":
 		userStates[message.Chat.ID] = "select_queue_for_action"
 		queueActions[message.Chat.ID] = "admin"
 		showQueues(bot, message.Chat.ID)
 
+	case "Расписание (Админ)":
+		if !canManageGlobal(message.From.ID) {
+			msg := tgbotapi.NewMessage(message.Chat.ID, "Расписание могут менять только администраторы бота.")
+			msg.ReplyMarkup = mainMenu()
+			send(bot, msg)
+			break
+		}
+		showTemplatesMenu(bot, message.Chat.ID)
+
 	default:
 		msg := tgbotapi.NewMessage(message.Chat.ID, "Неверная команда. Пожалуйста, используйте меню.")
-		bot.Send(msg)
+		send(bot, msg)
 	}
 }
 
 func mainMenu() tgbotapi.ReplyKeyboardMarkup {
 	buttons := [][]tgbotapi.KeyboardButton{
 		{tgbotapi.NewKeyboardButton("Зайти в очередь")},
-		{tgbotapi.NewKeyboardButton("Показать очередь")},
-		{tgbotapi.NewKeyboardButton("Создать очередь")},
+		{tgbotapi.NewKeyboardButton("Показать очередь"), tgbotapi.NewKeyboardButton("Мои очереди")},
+		{tgbotapi.NewKeyboardButton("Поменяться местами"), tgbotapi.NewKeyboardButton("Пропустить вперёд")},
+		{tgbotapi.NewKeyboardButton("Мои лабы"), tgbotapi.NewKeyboardButton("Уведомления о месте")},
+		{tgbotapi.NewKeyboardButton("Создать очередь"), tgbotapi.NewKeyboardButton("Курсы")},
 		{tgbotapi.NewKeyboardButton("Изменить очередь (Админ)")},
+		{tgbotapi.NewKeyboardButton("Расписание (Админ)")},
 	}
 	return tgbotapi.NewReplyKeyboard(buttons...)
 }
 
-// Показать список очередей
-func showQueues(bot *tgbotapi.BotAPI, chatID int64) {
-	rows, err := db.Query("SELECT id, name FROM queues")
-	if err != nil {
-		log.Printf("Ошибка при загрузке очередей: %v", err)
-		msg := tgbotapi.NewMessage(chatID, "Ошибка при загрузке очередей.")
-		bot.Send(msg)
-		return
-	}
-	defer rows.Close()
-
-	var buttons [][]tgbotapi.KeyboardButton
-	for rows.Next() {
-		var id int
-		var name string
-		if err := rows.Scan(&id, &name); err == nil {
-			buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(
-				tgbotapi.NewKeyboardButton(name),
-			))
-		}
-	}
-
-	if len(buttons) == 0 {
-		msg := tgbotapi.NewMessage(chatID, "Очередей пока нет.")
-		bot.Send(msg)
-		delete(userStates, chatID)
-		delete(queueActions, chatID)
-		return
-	}
-
-	msg := tgbotapi.NewMessage(chatID, "Выберите очередь:")
-	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
-	bot.Send(msg)
-}
-
 func handleQueueActionSelection(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	queueName := message.Text
 	if queueName == "Назад в главное меню" {
 
 		msg := tgbotapi.NewMessage(message.Chat.ID, "Возвращаю в главное меню")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 		delete(userStates, message.Chat.ID)
 		delete(queueActions, message.Chat.ID)
+		delete(queueListViews, message.Chat.ID)
 		return
 	}
 	var queueID int
 
-	err := db.QueryRow("SELECT id FROM queues WHERE name = ?", queueName).Scan(&queueID)
+	// Точное название выбирает очередь, всё остальное — поиск.
+	// В разных курсах названия могут совпадать: тогда тоже поиск.
+	var matches int
+	var minID sql.NullInt64
+	err := db.QueryRow("SELECT COUNT(*), MIN(q.id) FROM queues q WHERE q.name_key = ? AND "+visibleQueueCondition,
+		queueNameKey(queueName), message.Chat.ID).Scan(&matches, &minID)
+	if err == nil && matches != 1 {
+		searchQueues(bot, message.Chat.ID, queueName)
+		return
+	}
+	queueID = int(minID.Int64)
 	if err != nil {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "Такой очереди не существует. Попробуйте снова.")
+		log.Printf("Ошибка при загрузке очередей: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Ошибка при загрузке очередей.")
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
 
-	switch queueActions[message.Chat.ID] {
+	runQueueAction(bot, message.Chat.ID, queueID, message.From)
+}
+
+// Выполнение действия, ради которого выбиралась очередь
+func runQueueAction(bot *tgbotapi.BotAPI, chatID int64, queueID int, from *tgbotapi.User) {
+	switch queueActions[chatID] {
 	case "join":
-		addUserToQueue(bot, message.Chat.ID, queueID, message.From.UserName)
+		addUserToQueue(bot, chatID, queueID, from.UserName, nil)
 	case "show":
-		showQueueEntries(bot, message.Chat.ID, queueID)
+		showQueueEntries(bot, chatID, queueID)
+	case "swap":
+		startSwap(bot, chatID, queueID)
+	case "defer":
+		startDefer(bot, chatID, queueID)
 	case "admin":
-
-		adminQueueMenu(bot, message.Chat.ID, queueID)
+		q, err := loadQueue(queueID)
+		allowed := false
+		if err == nil {
+			allowed, err = canManageQueue(chatID, q)
+		}
+		if err != nil {
+			log.Printf("Ошибка проверки прав: %v", err)
+		}
+		if !allowed {
+			msg := tgbotapi.NewMessage(chatID, "Управлять этой очередью могут только администраторы курса.")
+			msg.ReplyMarkup = mainMenu()
+			send(bot, msg)
+			break
+		}
+		adminQueueMenu(bot, chatID, queueID)
 	}
-	if queueActions[message.Chat.ID] != "admin" {
-		delete(userStates, message.Chat.ID)
-		delete(queueActions, message.Chat.ID)
+	delete(queueListViews, chatID)
+	if userStates[chatID] == "select_queue_for_action" {
+		delete(userStates, chatID)
+		delete(queueActions, chatID)
 	}
 }
 
@@ -367,52 +625,253 @@ func adminQueueMenu(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
 	// Формируем сообщение с меню администратора
 	msg := tgbotapi.NewMessage(chatID, "Вы вошли в режим управления очередью. Выберите действие:")
 	msg.ReplyMarkup = adminMenuKeyboard()
-	bot.Send(msg)
+	send(bot, msg)
 }
 
 // Клавиатура для меню администратора
 func adminMenuKeyboard() tgbotapi.ReplyKeyboardMarkup {
-	buttons := [][]tgbotapi.KeyboardButton{
-		{tgbotapi.NewKeyboardButton("Очистить очередь")},
+	rows := [][]tgbotapi.KeyboardButton{
+		{tgbotapi.NewKeyboardButton("Следующий")},
+		{tgbotapi.NewKeyboardButton("Переместить участника"), tgbotapi.NewKeyboardButton("Добавить участника")},
+		{tgbotapi.NewKeyboardButton("Приоритет участника"), tgbotapi.NewKeyboardButton("Написать всем в очереди")},
+		{tgbotapi.NewKeyboardButton("Очистить очередь"), tgbotapi.NewKeyboardButton("Экспорт")},
 		{tgbotapi.NewKeyboardButton("Удалить очередь")},
-		{tgbotapi.NewKeyboardButton("Удалить пользователя из очереди")},
+		{tgbotapi.NewKeyboardButton("Удалить пользователя из очереди"), tgbotapi.NewKeyboardButton("Список группы")},
+		{tgbotapi.NewKeyboardButton("Переименовать очередь"), tgbotapi.NewKeyboardButton("Группа очереди")},
+		{tgbotapi.NewKeyboardButton("Настройки очереди"), tgbotapi.NewKeyboardButton("Ссылка-приглашение")},
 		{tgbotapi.NewKeyboardButton("Назад в главное меню")},
 	}
+
+	// Кнопки выключенных в настройках функций не показываем
+	var buttons [][]tgbotapi.KeyboardButton
+	for _, row := range rows {
+		row = slices.DeleteFunc(row, func(b tgbotapi.KeyboardButton) bool { return !adminButtonEnabled(b.Text) })
+		if len(row) > 0 {
+			buttons = append(buttons, row)
+		}
+	}
 	return tgbotapi.NewReplyKeyboard(buttons...)
 }
 
-func addUserToQueue(bot *tgbotapi.BotAPI, chatID int64, queueID int, username string) {
-	_, err := db.Exec("INSERT INTO queue_entries (queue_id, user_id, username) VALUES (?, ?, ?)", queueID, chatID, username)
+// Доступна ли кнопка меню администратора с учётом настроек
+func adminButtonEnabled(text string) bool {
+	switch text {
+	case "Написать всем в очереди":
+		return config.Features.Broadcast
+	case "Ссылка-приглашение":
+		return config.Features.Invites
+	case "Список группы":
+		return config.Features.Rosters
+	case "Экспорт":
+		return config.Features.Export
+	case "Группа очереди":
+		return config.Features.Groups
+	}
+	return true
+}
+
+// Запись в очередь. Если в очереди задан список лаб, а labs не выбраны,
+// сначала спрашиваем, какие лабы сдаются.
+func addUserToQueue(bot *tgbotapi.BotAPI, chatID int64, queueID int, username string, labs []int) {
+	q, err := loadQueue(queueID)
 	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
 		msg := tgbotapi.NewMessage(chatID, "Ошибка при добавлении в очередь.")
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Вы добавлены в очередь!"))
+
+	if q.Status != queueStatusOpen && !allowsPreRegistration(q) {
+		msg := tgbotapi.NewMessage(chatID, queueClosedText(q))
+		msg.ReplyMarkup = subscribeKeyboard(queueID)
+		send(bot, msg)
+		msg = tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	refusal, err := entryRefusal(bot, q, chatID)
+	if err != nil {
+		log.Printf("Ошибка проверки очереди: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при добавлении в очередь.")
+		send(bot, msg)
+		return
+	}
+	if refusal != "" {
+		msg := tgbotapi.NewMessage(chatID, refusal)
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	allowed, err := rosterAllows(q, chatID, username)
+	if err != nil {
+		log.Printf("Ошибка проверки списка группы: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при добавлении в очередь.")
+		send(bot, msg)
+		return
+	}
+	if !allowed {
+		startRosterClaim(bot, chatID, q)
+		return
+	}
+
+	if len(q.Labs) > 0 && len(labs) == 0 {
+		startLabSelection(bot, chatID, q)
+		return
+	}
+
+	err = insertQueueEntry(q, chatID, username, labs)
+	if err != nil {
+		log.Printf("Ошибка добавления в очередь: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при добавлении в очередь.")
+		send(bot, msg)
+		return
+	}
+	text := "Вы добавлены в очередь!"
+	place, wait := joinedPlace(q, chatID)
+	if place > 0 {
+		text += fmt.Sprintf("\nВаше место: %d.", place)
+		if wait != "" {
+			text += " Ожидание: " + wait + "."
+		}
+		announceToGroup(db, q, fmt.Sprintf("%s теперь в очереди \"%s\", место %d.", entryLabel(QueueEntry{UserID: chatID, Username: username}), q.Name, place))
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = mainMenu()
-	bot.Send(msg)
+	send(bot, msg)
+}
+
+// Причина, по которой пользователь не может записаться в очередь
+// (статус очереди проверяется отдельно). Пустая строка — можно.
+func entryRefusal(bot *tgbotapi.BotAPI, q Queue, userID int64) (string, error) {
+	if q.GroupChatID != 0 && config.Features.Groups {
+		member, err := isGroupMember(bot, q.GroupChatID, userID)
+		if err != nil {
+			log.Printf("Ошибка проверки участия в группе %d: %v", q.GroupChatID, err)
+			return "Не удалось проверить, состоите ли вы в группе очереди. Попробуйте позже.", nil
+		}
+		if !member {
+			return fmt.Sprintf("Записаться могут только участники группы «%s».", q.GroupTitle), nil
+		}
+	}
+
+	var total, own int
+	err := db.QueryRow("SELECT COUNT(*), COUNT(CASE WHEN user_id = ? THEN 1 END) FROM queue_entries WHERE queue_id = ?", userID, q.ID).Scan(&total, &own)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case own > 0 && !q.AllowMultiple:
+		return "Вы уже записаны в эту очередь.", nil
+	case q.MaxSize > 0 && total >= q.MaxSize:
+		return "В очереди нет свободных мест.", nil
+	}
+	return "", nil
+}
+
+// Место только что записавшегося пользователя и ожидание (если очередь открыта).
+// place 0 — запись не найдена.
+func joinedPlace(q Queue, userID int64) (place int, wait string) {
+	entries, err := loadEntries(q.ID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		return 0, ""
+	}
+	// Новая запись пользователя — с наибольшим id
+	index := -1
+	for i, e := range entries {
+		if e.UserID == userID && (index < 0 || e.ID > entries[index].ID) {
+			index = i
+		}
+	}
+	if index < 0 {
+		return 0, ""
+	}
+	if q.Status == queueStatusOpen {
+		avg, err := averageServiceTime(q.ID)
+		if err != nil {
+			log.Printf("Ошибка расчёта времени ожидания: %v", err)
+		}
+		wait = formatWait(waitForPosition(avg, index))
+	}
+	return index + 1, wait
 }
 
 func showQueueEntries(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
-	rows, err := db.Query("SELECT username FROM queue_entries WHERE queue_id = ?", queueID)
+	q, err := loadQueue(queueID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(chatID, "Ошибка при получении списка очереди.")
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
-	defer rows.Close()
 
-	var users []string
-	for rows.Next() {
-		var username string
-		rows.Scan(&username)
-		users = append(users, "@"+username)
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при получении списка очереди.")
+		send(bot, msg)
+		return
+	}
+
+	order := describeOrder(q)
+	if slices.ContainsFunc(entries, func(e QueueEntry) bool { return e.Priority > 0 }) {
+		order += "\n⭐ — приоритет, назначенный преподавателем: такие участники идут первыми."
+	}
+	if q.Status == queueStatusOpen && len(entries) > 0 {
+		if avg, err := averageServiceTime(queueID); err == nil {
+			order += fmt.Sprintf("\nОжидание рассчитано по среднему времени приёма: %s на человека.", strings.TrimPrefix(formatWait(avg), "~"))
+		}
 	}
 
-	msgText := "Состав очереди:\n" + strings.Join(users, "\n")
+	msgText := formatQueueInfo(q) + "\n" + order + "\n\nСостав очереди:\n" + formatQueueEntries(q, entries)
 	msg := tgbotapi.NewMessage(chatID, msgText)
 	msg.ReplyMarkup = mainMenu()
-	bot.Send(msg)
+	send(bot, msg)
+}
+
+// Нумерованный список записей очереди
+func formatQueueEntries(q Queue, entries []QueueEntry) string {
+	// Для порядка по приоритету показываем, сколько раз человека уже принимали
+	served := make(map[int]int)
+	if q.OrderPolicy == orderPriority {
+		counts, err := entryServedCounts(db, q.ID)
+		if err != nil {
+			log.Printf("Ошибка подсчёта сдач: %v", err)
+		}
+		for _, c := range counts {
+			served[c.entryID] = c.served
+		}
+	}
+
+	// Ожидание имеет смысл, только пока очередь идёт
+	var avg time.Duration
+	if q.Status == queueStatusOpen {
+		var err error
+		if avg, err = averageServiceTime(q.ID); err != nil {
+			log.Printf("Ошибка расчёта времени ожидания: %v", err)
+		}
+	}
+
+	var users []string
+	for i, e := range entries {
+		line := fmt.Sprintf("%d. %s", i+1, entryLabel(e)) + entryLabsText(e)
+		if e.Priority > 0 {
+			line += " " + strings.Repeat("⭐", e.Priority)
+		}
+		if q.OrderPolicy == orderPriority {
+			line += fmt.Sprintf(" (%d)", served[e.ID])
+		}
+		if avg > 0 {
+			line += " — " + formatWait(waitForPosition(avg, i))
+		}
+		users = append(users, line)
+	}
+	if len(users) == 0 {
+		return "пока никого нет"
+	}
+	return strings.Join(users, "\n")
 }
 
 // package main
@@ -669,54 +1128,163 @@ func showQueueEntries(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
 //
 // // Обработка создания очереди
 func handleQueueCreation(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	queueName := message.Text
-
+	chatID := message.Chat.ID
 	defer func() {
-		delete(userStates, message.Chat.ID) // Сброс состояния
-		delete(queueActions, message.Chat.ID)
+		if userStates[chatID] == "creating_queue" {
+			delete(userStates, chatID) // Сброс состояния
+		}
+		delete(queueActions, chatID)
 	}()
 
-	if slices.Contains(PermitedNames, queueName) {
-		log.Printf("Ошибка создания очереди: %v", "название совпадает с командами меню.")
-		msg := tgbotapi.NewMessage(message.Chat.ID, "Ошибка при создании очереди.")
+	queueName, err := validateQueueName(message.Text)
+	if err != nil {
+		text := "Ошибка при создании очереди."
+		if isQueueNameError(err) {
+			text += " " + err.Error()
+		} else {
+			log.Printf("Ошибка проверки названия очереди: %v", err)
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
 		msg.ReplyMarkup = mainMenu()
-		bot.Send(msg)
+		send(bot, msg)
 		return
 	}
-	_, err := db.Exec("INSERT INTO queues (name, created_by) VALUES (?, ?)", queueName, message.Chat.ID)
+
+	// Администратор курса выбирает, к какому курсу отнести очередь
+	courses, err := adminCourses(chatID)
 	if err != nil {
-		log.Printf("Ошибка создания очереди: %v", err)
-		msg := tgbotapi.NewMessage(message.Chat.ID, "Ошибка при создании очереди.")
-		bot.Send(msg)
+		log.Printf("Ошибка загрузки курсов: %v", err)
+	}
+	if len(courses) > 0 {
+		askQueueCourse(bot, chatID, queueName, courses)
 		return
 	}
+	createQueue(bot, chatID, queueName, 0)
+}
 
-	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Очередь \"%s\" успешно создана!", queueName))
-	msg.ReplyMarkup = mainMenu()
-	bot.Send(msg)
+// Создание очереди; courseID 0 — общая очередь без курса
+func createQueue(bot *tgbotapi.BotAPI, chatID int64, name string, courseID int) {
+	name, err := checkQueueName(name, courseID, 0)
+	if err != nil {
+		text := "Ошибка при создании очереди."
+		if isQueueNameError(err) {
+			text += " " + err.Error()
+		} else {
+			log.Printf("Ошибка проверки названия очереди: %v", err)
+		}
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
 
-	delete(userStates, message.Chat.ID) // Сбрасываем состояние пользователя
+	var course sql.NullInt64
+	if courseID != 0 {
+		course = sql.NullInt64{Int64: int64(courseID), Valid: true}
+	}
+	_, err = db.Exec("INSERT INTO queues (name, name_key, created_by, course_id) VALUES (?, ?, ?, ?)", name, queueNameKey(name), chatID, course)
+	if err = queueNameConflict(err); err != nil {
+		text := "Ошибка при создании очереди."
+		if isQueueNameError(err) {
+			text += " " + err.Error()
+		} else {
+			log.Printf("Ошибка создания очереди: %v", err)
+		}
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Очередь \"%s\" успешно создана!", name))
+	msg.ReplyMarkup = mainMenu()
+	send(bot, msg)
 }
 
 func handleCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery) {
+	rememberUser(callbackQuery.From)
 	data := callbackQuery.Data
+	action, arg, _ := strings.Cut(data, ":")
+	answer := "Запрос обработан!"
+
+	switch action {
+	case "call_ack":
+		entryID, _ := strconv.Atoi(arg)
+		answer = acknowledgeCall(bot, entryID, callbackQuery.From.ID)
+	case "defer":
+		queueID, _ := strconv.Atoi(arg)
+		answer = deferOwnEntry(bot, callbackQuery.From.ID, queueID, 1)
+	case "swap_accept", "swap_decline":
+		requestID, _ := strconv.Atoi(arg)
+		answer = handleSwapAnswer(bot, callbackQuery, requestID, action == "swap_accept")
+	case "template_delete":
+		templateID, _ := strconv.Atoi(arg)
+		if !canManageGlobal(callbackQuery.From.ID) {
+			answer = "Расписание могут менять только администраторы бота."
+			break
+		}
+		if err := deleteTemplate(templateID); err != nil {
+			log.Printf("Ошибка удаления шаблона: %v", err)
+			answer = "Не удалось удалить шаблон."
+		} else {
+			answer = "Шаблон удалён."
+			showTemplatesMenu(bot, callbackQuery.Message.Chat.ID)
+		}
+	case "qpage", "qall", "qreset", "qpick":
+		answer = handleQueueListCallback(bot, callbackQuery, action, arg)
+	case "course_link", "course_leave":
+		courseID, _ := strconv.Atoi(arg)
+		answer = handleCourseCallback(bot, callbackQuery, action, courseID)
+	case "jr_approve", "jr_reject":
+		requestID, _ := strconv.Atoi(arg)
+		answer = decideJoinRequest(bot, callbackQuery, requestID, action == "jr_approve")
+	case "group_unbind":
+		queueID, _ := strconv.Atoi(arg)
+		answer = unbindGroup(bot, callbackQuery, queueID)
+	case "join":
+		queueID, _ := strconv.Atoi(arg)
+		// Кнопка могла прийти из чужого сообщения: записываем только в видимые очереди
+		visible, err := queueVisible(queueID, callbackQuery.From.ID)
+		if err != nil {
+			log.Printf("Ошибка проверки доступа к очереди: %v", err)
+			answer = "Ошибка при записи в очередь."
+			break
+		}
+		if !visible {
+			answer = "Эта очередь вам недоступна."
+			break
+		}
+		answer = "Записываю…"
+		addUserToQueue(bot, callbackQuery.From.ID, queueID, callbackQuery.From.UserName, nil)
+	case "leave":
+		queueID, _ := strconv.Atoi(arg)
+		answer = leaveQueue(bot, callbackQuery, queueID)
+	case "subscribe":
+		queueID, _ := strconv.Atoi(arg)
+		if err := subscribeToQueue(callbackQuery.From.ID, queueID); err != nil {
+			log.Printf("Ошибка подписки на очередь: %v", err)
+			answer = "Не удалось подписаться."
+		} else {
+			answer = "Пришлю сообщение, когда очередь откроется."
+		}
+	}
 
 	if data == "join_queue" {
 		// Логика записи в очередь
 		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "Вы записаны в очередь!")
-		bot.Send(msg)
+		send(bot, msg)
 	} else if data == "create_queue" {
 		// Логика создания новой очереди
 		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "Создание новой очереди...")
-		bot.Send(msg)
+		send(bot, msg)
 	} else if data == "edit_queue" {
 		// Логика редактирования очереди
 		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "Редактирование очереди...")
-		bot.Send(msg)
+		send(bot, msg)
 	}
 
 	// Ответ на CallbackQuery
-	callback := tgbotapi.NewCallback(callbackQuery.ID, "Запрос обработан!")
+	callback := tgbotapi.NewCallback(callbackQuery.ID, answer)
 	if _, err := bot.Request(callback); err != nil {
 		log.Printf("Ошибка при ответе на CallbackQuery: %v", err)
 	}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Миграции схемы базы данных. Применяются строго по порядку, номер последней
+// применённой миграции хранится в PRAGMA user_version. Новые миграции
+// добавляются только в конец списка.
+var migrations = []func(tx *sql.Tx) error{
+	// 1: нормализованное имя очереди для проверки уникальности без учёта регистра
+	func(tx *sql.Tx) error {
+		if _, err := tx.Exec("ALTER TABLE queues ADD COLUMN name_key TEXT"); err != nil {
+			return err
+		}
+		rows, err := tx.Query("SELECT id, name FROM queues")
+		if err != nil {
+			return err
+		}
+		keys := make(map[int]string)
+		for rows.Next() {
+			var id int
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return err
+			}
+			keys[id] = queueNameKey(name)
+		}
+		rows.Close()
+		for id, key := range keys {
+			if _, err := tx.Exec("UPDATE queues SET name_key = ? WHERE id = ?", key, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 2: описание, сведения о лабах и настройки очереди
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE queues ADD COLUMN description TEXT NOT NULL DEFAULT '';
+		ALTER TABLE queues ADD COLUMN subject TEXT NOT NULL DEFAULT '';
+		ALTER TABLE queues ADD COLUMN teacher TEXT NOT NULL DEFAULT '';
+		ALTER TABLE queues ADD COLUMN room TEXT NOT NULL DEFAULT '';
+		ALTER TABLE queues ADD COLUMN labs TEXT NOT NULL DEFAULT '';
+		ALTER TABLE queues ADD COLUMN max_size INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE queues ADD COLUMN allow_multiple BOOLEAN NOT NULL DEFAULT 0;
+		ALTER TABLE queues ADD COLUMN status TEXT NOT NULL DEFAULT 'open';
+		`)
+		return err
+	},
+	// 3: расписание открытия и закрытия, подписки на уведомления
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE queues ADD COLUMN opens_at TIMESTAMP;
+		ALTER TABLE queues ADD COLUMN closes_at TIMESTAMP;
+		CREATE TABLE queue_subscribers (
+			queue_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			PRIMARY KEY (queue_id, user_id),
+			FOREIGN KEY(queue_id) REFERENCES queues(id)
+		);
+		`)
+		return err
+	},
+	// 4: шаблоны повторяющихся очередей по расписанию занятий
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE queue_templates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			weekday INTEGER NOT NULL,
+			start_time TEXT NOT NULL,
+			parity TEXT NOT NULL DEFAULT 'all',
+			open_before INTEGER NOT NULL DEFAULT 0,
+			created_by INTEGER,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		ALTER TABLE queues ADD COLUMN template_id INTEGER REFERENCES queue_templates(id);
+		ALTER TABLE queues ADD COLUMN session_at TIMESTAMP;
+		CREATE UNIQUE INDEX queues_template_session ON queues(template_id, session_at) WHERE template_id IS NOT NULL;
+		`)
+		return err
+	},
+	// 5: порядок очереди, позиции записей и история приёма
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE queues ADD COLUMN order_policy TEXT NOT NULL DEFAULT 'fifo';
+		ALTER TABLE queue_entries ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+		UPDATE queue_entries SET position = id;
+		CREATE TABLE served_entries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			queue_id INTEGER,
+			user_id INTEGER,
+			username TEXT,
+			joined_at TIMESTAMP,
+			served_at TIMESTAMP,
+			FOREIGN KEY(queue_id) REFERENCES queues(id)
+		);
+		CREATE INDEX served_entries_user ON served_entries(user_id, served_at);
+		`)
+		return err
+	},
+	// 6: уровни приоритета записей и справочник пользователей
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE queue_entries ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+		CREATE TABLE users (
+			user_id INTEGER PRIMARY KEY,
+			username TEXT,
+			first_name TEXT,
+			last_name TEXT,
+			updated_at TIMESTAMP
+		);
+		CREATE INDEX users_username ON users(username COLLATE NOCASE);
+		`)
+		return err
+	},
+	// 7: предложения поменяться местами
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE swap_requests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			queue_id INTEGER NOT NULL,
+			from_entry_id INTEGER NOT NULL,
+			to_entry_id INTEGER NOT NULL,
+			from_user_id INTEGER NOT NULL,
+			to_user_id INTEGER NOT NULL,
+			message_id INTEGER,
+			status TEXT NOT NULL DEFAULT 'pending',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			FOREIGN KEY(queue_id) REFERENCES queues(id)
+		);
+		`)
+		return err
+	},
+	// 8: пропуск других вперёд
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE queues ADD COLUMN max_defers INTEGER NOT NULL DEFAULT 2;
+		ALTER TABLE queue_entries ADD COLUMN defers INTEGER NOT NULL DEFAULT 0;
+		`)
+		return err
+	},
+	// 9: подтверждение вызова и учёт неявок
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE queues ADD COLUMN call_timeout INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE queues ADD COLUMN max_no_shows INTEGER NOT NULL DEFAULT 2;
+		ALTER TABLE queue_entries ADD COLUMN called_at TIMESTAMP;
+		ALTER TABLE queue_entries ADD COLUMN called_by INTEGER;
+		ALTER TABLE queue_entries ADD COLUMN confirmed BOOLEAN NOT NULL DEFAULT 0;
+		ALTER TABLE queue_entries ADD COLUMN no_shows INTEGER NOT NULL DEFAULT 0;
+		`)
+		return err
+	},
+	// 10: сдаваемые лабы, через запятую
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE queue_entries ADD COLUMN labs TEXT NOT NULL DEFAULT '';
+		ALTER TABLE served_entries ADD COLUMN labs TEXT NOT NULL DEFAULT '';
+		`)
+		return err
+	},
+	// 11: результаты сдачи лаб
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE lab_results (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			queue_id INTEGER,
+			user_id INTEGER,
+			username TEXT,
+			subject TEXT NOT NULL,
+			lab INTEGER NOT NULL,
+			outcome TEXT NOT NULL,
+			comment TEXT NOT NULL DEFAULT '',
+			graded_by INTEGER,
+			graded_at TIMESTAMP
+		);
+		CREATE INDEX lab_results_user ON lab_results(user_id, graded_at);
+		`)
+		return err
+	},
+	// 12: уведомления о месте в очереди и очередь исходящих сообщений
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE users ADD COLUMN notify BOOLEAN NOT NULL DEFAULT 0;
+		ALTER TABLE queues ADD COLUMN notify_positions TEXT NOT NULL DEFAULT '2, 3, 5';
+		ALTER TABLE queue_entries ADD COLUMN notified_position INTEGER NOT NULL DEFAULT 0;
+		CREATE TABLE outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			text TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			created_at TIMESTAMP,
+			sent_at TIMESTAMP
+		);
+		CREATE INDEX outbox_status ON outbox(status, id);
+		`)
+		return err
+	},
+	// 13: отложенные повторы отправки и массовые рассылки
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE outbox ADD COLUMN next_attempt_at TIMESTAMP;
+		ALTER TABLE outbox ADD COLUMN job_id INTEGER;
+		CREATE TABLE outbox_jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner_id INTEGER NOT NULL DEFAULT 0,
+			title TEXT NOT NULL,
+			created_at TIMESTAMP,
+			finished_at TIMESTAMP
+		);
+		CREATE INDEX outbox_job ON outbox(job_id);
+		`)
+		return err
+	},
+	// 14: рассылка копий сообщений (с фото, файлами)
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE outbox ADD COLUMN from_chat_id INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE outbox ADD COLUMN message_id INTEGER NOT NULL DEFAULT 0;
+		`)
+		return err
+	},
+	// 15: курсы и группы, которым принадлежат очереди
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE courses (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			name_key TEXT NOT NULL UNIQUE,
+			invite_code TEXT NOT NULL UNIQUE,
+			created_by INTEGER,
+			created_at TIMESTAMP
+		);
+		CREATE TABLE course_members (
+			course_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL DEFAULT 'student',
+			joined_at TIMESTAMP,
+			PRIMARY KEY (course_id, user_id),
+			FOREIGN KEY(course_id) REFERENCES courses(id)
+		);
+		CREATE INDEX course_members_user ON course_members(user_id);
+		ALTER TABLE queues ADD COLUMN course_id INTEGER REFERENCES courses(id);
+		`)
+		return err
+	},
+	// 16: ссылки-приглашения с ограничением срока и числа использований
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE invite_tokens (
+			token TEXT PRIMARY KEY,
+			kind TEXT NOT NULL,
+			target_id INTEGER NOT NULL,
+			created_by INTEGER,
+			created_at TIMESTAMP,
+			expires_at TIMESTAMP,
+			max_uses INTEGER NOT NULL DEFAULT 0,
+			uses INTEGER NOT NULL DEFAULT 0
+		);
+		`)
+		return err
+	},
+	// 17: привязка очереди к группе в Telegram
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE queues ADD COLUMN group_chat_id INTEGER;
+		ALTER TABLE queues ADD COLUMN group_title TEXT NOT NULL DEFAULT '';
+		`)
+		return err
+	},
+	// 18: списки групп и заявки на вступление
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		CREATE TABLE roster (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			course_id INTEGER REFERENCES courses(id),
+			queue_id INTEGER REFERENCES queues(id),
+			full_name TEXT NOT NULL,
+			name_key TEXT NOT NULL,
+			username TEXT NOT NULL DEFAULT '',
+			user_id INTEGER
+		);
+		CREATE INDEX roster_course ON roster (course_id);
+		CREATE INDEX roster_queue ON roster (queue_id);
+		CREATE TABLE join_requests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			queue_id INTEGER NOT NULL REFERENCES queues(id),
+			user_id INTEGER NOT NULL,
+			username TEXT NOT NULL DEFAULT '',
+			full_name TEXT NOT NULL,
+			status TEXT NOT NULL,
+			created_at TIMESTAMP,
+			decided_by INTEGER,
+			decided_at TIMESTAMP
+		);
+		`)
+		return err
+	},
+	// 19: учебная группа в списке (из импорта таблицы)
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`ALTER TABLE roster ADD COLUMN study_group TEXT NOT NULL DEFAULT ''`)
+		return err
+	},
+	// 20: уникальность названия очереди в пределах курса на уровне базы.
+	// Совпадающие названия, созданные до проверки, получают номер очереди.
+	func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT id, name, COALESCE(course_id, 0) FROM queues ORDER BY id")
+		if err != nil {
+			return err
+		}
+		type queueName struct {
+			id   int
+			name string
+		}
+		taken := make(map[string]bool) // курс + ключ названия
+		var renames []queueName
+		for rows.Next() {
+			var q queueName
+			var courseID int
+			if err := rows.Scan(&q.id, &q.name, &courseID); err != nil {
+				rows.Close()
+				return err
+			}
+			name := q.name
+			key := fmt.Sprintf("%d:%s", courseID, queueNameKey(q.name))
+			for n := 1; taken[key]; n++ {
+				suffix := fmt.Sprintf(" (%d)", q.id)
+				if n > 1 {
+					suffix = fmt.Sprintf(" (%d-%d)", q.id, n)
+				}
+				q.name = queueNameWithSuffix(name, suffix)
+				key = fmt.Sprintf("%d:%s", courseID, queueNameKey(q.name))
+			}
+			taken[key] = true
+			if q.name != name {
+				renames = append(renames, q)
+			}
+		}
+		rows.Close()
+		for _, q := range renames {
+			if _, err := tx.Exec("UPDATE queues SET name = ?, name_key = ? WHERE id = ?", q.name, queueNameKey(q.name), q.id); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec("CREATE UNIQUE INDEX queues_course_name ON queues (COALESCE(course_id, 0), name_key)")
+		return err
+	},
+}
+
+// Применение недостающих миграций
+func migrateDB(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migrations[i](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("миграция %d: %w", i+1, err)
+		}
+		// PRAGMA не поддерживает плейсхолдеры
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// Миграция 20 переименовывает совпадающие названия, не выходя за лимит длины
+func TestMigrationUniqueQueueNames(t *testing.T) {
+	d, err := openDB("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// Схема до миграции 20 и очереди, созданные до проверки уникальности
+	for i, m := range migrations[:19] {
+		tx, err := d.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m(tx); err != nil {
+			t.Fatalf("миграция %d: %v", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	long := strings.Repeat("я", maxQueueNameLen)
+	for _, name := range []string{"Лаба", "ЛАБА", "лаба", "Лаба (2)", long, long} {
+		if _, err := d.Exec("INSERT INTO queues (name, name_key) VALUES (?, ?)", name, queueNameKey(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrations[19](tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := d.Query("SELECT name, name_key FROM queues ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name, key string
+		if err := rows.Scan(&name, &key); err != nil {
+			t.Fatal(err)
+		}
+		if key != queueNameKey(name) {
+			t.Errorf("%q: ключ %q не совпадает с названием", name, key)
+		}
+		if utf8.RuneCountInString(name) > maxQueueNameLen {
+			t.Errorf("%q: длиннее %d символов", name, maxQueueNameLen)
+		}
+		names = append(names, name)
+	}
+	want := []string{"Лаба", "ЛАБА (2)", "лаба (3)", "Лаба (2) (4)", long, strings.Repeat("я", maxQueueNameLen-4) + " (6)"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("названия %q, ожидались %q", names, want)
+	}
+
+	if _, err := d.Exec("INSERT INTO queues (name, name_key) VALUES ('Лаба', 'лаба')"); err == nil {
+		t.Error("индекс не запрещает совпадающие названия")
+	}
+}
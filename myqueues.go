@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Очереди, в которых записан пользователь: место, сколько впереди и ожидание.
+// Под списком — кнопки выхода из каждой очереди.
+func myQueuesView(userID int64) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	rows, err := db.Query(`SELECT DISTINCT q.id FROM queues q JOIN queue_entries e ON e.queue_id = q.id
+	WHERE e.user_id = ? AND q.status != ? ORDER BY q.session_at, q.id`, userID, queueStatusArchived)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	var queueIDs []int
+	for rows.Next() {
+		var queueID int
+		if err := rows.Scan(&queueID); err != nil {
+			rows.Close()
+			return "", tgbotapi.InlineKeyboardMarkup{}, err
+		}
+		queueIDs = append(queueIDs, queueID)
+	}
+	rows.Close()
+
+	var lines []string
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, queueID := range queueIDs {
+		q, err := loadQueue(queueID)
+		if err != nil {
+			return "", tgbotapi.InlineKeyboardMarkup{}, err
+		}
+		entries, err := loadEntries(queueID)
+		if err != nil {
+			return "", tgbotapi.InlineKeyboardMarkup{}, err
+		}
+		index := slices.IndexFunc(entries, func(e QueueEntry) bool { return e.UserID == userID })
+		if index < 0 {
+			continue
+		}
+
+		line := fmt.Sprintf("«%s»: место %d", q.Name, index+1)
+		if index > 0 {
+			line += fmt.Sprintf(", впереди %d", index)
+		}
+		if q.Status == queueStatusOpen {
+			avg, err := averageServiceTime(queueID)
+			if err != nil {
+				log.Printf("Ошибка расчёта времени ожидания: %v", err)
+			}
+			line += ", ожидание: " + formatWait(waitForPosition(avg, index))
+		} else {
+			line += " (" + queueStatusNames[q.Status] + ")"
+		}
+		lines = append(lines, line)
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Выйти из «"+q.Name+"»", fmt.Sprintf("leave:%d", queueID)),
+		))
+	}
+
+	if len(lines) == 0 {
+		return "Вы не записаны ни в одну очередь.", tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}, nil
+	}
+	return "Ваши очереди:\n" + strings.Join(lines, "\n"), tgbotapi.NewInlineKeyboardMarkup(buttons...), nil
+}
+
+func showMyQueues(bot *tgbotapi.BotAPI, chatID int64) {
+	text, keyboard, err := myQueuesView(chatID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очередей пользователя: %v", err)
+		send(bot, tgbotapi.NewMessage(chatID, "Ошибка при получении списка очередей."))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	if len(keyboard.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = keyboard
+	}
+	send(bot, msg)
+}
+
+// Выход из очереди по кнопке. Если уходит первый, вызываем следующего.
+func leaveQueue(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, queueID int) string {
+	userID := callbackQuery.From.ID
+
+	found, err := removeFromQueue(bot, queueID, userID)
+	if err != nil {
+		log.Printf("Ошибка выхода из очереди: %v", err)
+		return "Ошибка при выходе из очереди."
+	}
+	if !found {
+		return "Вас уже нет в этой очереди."
+	}
+	if q, err := loadQueue(queueID); err == nil {
+		announceToGroup(db, q, fmt.Sprintf("%s выходит из очереди \"%s\".", entryLabel(QueueEntry{UserID: userID, Username: callbackQuery.From.UserName}), q.Name))
+	}
+
+	// Обновляем список в том же сообщении
+	if callbackQuery.Message != nil {
+		if text, keyboard, err := myQueuesView(userID); err == nil {
+			send(bot, tgbotapi.NewEditMessageTextAndMarkup(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, text, keyboard))
+		}
+	}
+	return "Вы вышли из очереди."
+}
+
+// Удаление всех записей пользователя из очереди. Если уходит первый,
+// вызываем следующего. found false — пользователя в очереди не было.
+func removeFromQueue(bot *tgbotapi.BotAPI, queueID int, userID int64) (found bool, err error) {
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		return false, err
+	}
+	index := slices.IndexFunc(entries, func(e QueueEntry) bool { return e.UserID == userID })
+	if index < 0 {
+		return false, nil
+	}
+
+	if _, err := db.Exec("DELETE FROM queue_entries WHERE queue_id = ? AND user_id = ?", queueID, userID); err != nil {
+		return false, err
+	}
+
+	// Следующего вызываем, только если ушёл уже вызванный первый
+	if index == 0 && entries[0].CalledBy != 0 {
+		if q, err := loadQueue(queueID); err == nil && q.Status == queueStatusOpen {
+			if rest, err := loadEntries(queueID); err == nil && len(rest) > 0 {
+				callEntry(bot, rest[0], entries[0].CalledBy)
+			}
+		}
+	}
+	return true, nil
+}
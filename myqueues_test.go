@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Вызванный первый уходит из очереди: вызывается следующий
+func TestRemoveCalledHead(t *testing.T) {
+	setupTestDB(t)
+	bot, fake := newTestBot(t)
+	const teacher = 100
+	q := createTestQueue(t, "Лабы", 1, 2)
+
+	entries, err := loadEntries(q.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callEntry(bot, entries[0], teacher)
+
+	found, err := removeFromQueue(bot, q.ID, 1)
+	if err != nil || !found {
+		t.Fatalf("removeFromQueue: found = %v, err = %v", found, err)
+	}
+
+	entries, err = loadEntries(q.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].UserID != 2 {
+		t.Fatalf("в очереди %+v, ожидался только пользователь 2", entries)
+	}
+	if entries[0].CalledBy != teacher {
+		t.Errorf("следующий не вызван: called_by = %d", entries[0].CalledBy)
+	}
+	if texts := fake.messagesTo(2); len(texts) != 1 || !strings.Contains(texts[0], "подошла") {
+		t.Errorf("следующему отправлено %q", texts)
+	}
+}
+
+// Невызванный первый уходит: следующего не вызываем
+func TestRemoveUncalledHead(t *testing.T) {
+	setupTestDB(t)
+	bot, fake := newTestBot(t)
+	q := createTestQueue(t, "Лабы", 1, 2)
+
+	if _, err := removeFromQueue(bot, q.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := loadEntries(q.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].CalledBy != 0 {
+		t.Errorf("в очереди %+v, следующий не должен быть вызван", entries)
+	}
+	if texts := fake.messagesTo(2); len(texts) != 0 {
+		t.Errorf("следующему отправлено %q", texts)
+	}
+}
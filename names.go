@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	minQueueNameLen = 2
+	maxQueueNameLen = 64
+)
+
+var (
+	errQueueNameTooShort = fmt.Errorf("Название слишком короткое: нужно хотя бы %d символа.", minQueueNameLen)
+	errQueueNameTooLong  = fmt.Errorf("Название слишком длинное: не больше %d символов.", maxQueueNameLen)
+	errQueueNameNoText   = errors.New("Название должно содержать хотя бы одну букву или цифру.")
+	errQueueNameCommand  = errors.New("Название не может начинаться с «/» или совпадать с кнопкой меню.")
+	errQueueNameTaken    = errors.New("Очередь с таким названием уже есть. Выберите другое.")
+)
+
+// Приведение названия к каноническому виду: обрезка и схлопывание пробелов
+func normalizeQueueName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Ключ для сравнения названий без учёта регистра
+func queueNameKey(name string) string {
+	return strings.ToLower(normalizeQueueName(name))
+}
+
+// Проверка названия очереди. Возвращает нормализованное название
+// или ошибку с понятным пользователю текстом.
+func validateQueueName(name string) (string, error) {
+	name = normalizeQueueName(name)
+
+	length := utf8.RuneCountInString(name)
+	if length < minQueueNameLen {
+		return "", errQueueNameTooShort
+	}
+	if length > maxQueueNameLen {
+		return "", errQueueNameTooLong
+	}
+	if !strings.ContainsFunc(name, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+		return "", errQueueNameNoText
+	}
+
+	key := queueNameKey(name)
+	if strings.HasPrefix(name, "/") || slices.ContainsFunc(PermitedNames, func(s string) bool { return queueNameKey(s) == key }) {
+		return "", errQueueNameCommand
+	}
+	return name, nil
+}
+
+// Название с добавкой в конце. Начало при необходимости обрезается,
+// чтобы всё вместе уложилось в maxQueueNameLen.
+func queueNameWithSuffix(name, suffix string) string {
+	runes := []rune(normalizeQueueName(name))
+	if limit := maxQueueNameLen - utf8.RuneCountInString(suffix); len(runes) > limit {
+		runes = runes[:max(limit, 0)]
+	}
+	return strings.TrimSpace(string(runes)) + suffix
+}
+
+// Проверка, занято ли название другой очередью того же курса (0 — общие очереди).
+// exceptID позволяет не учитывать саму переименовываемую очередь.
+func queueNameTaken(name string, courseID, exceptID int) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM queues WHERE name_key = ? AND COALESCE(course_id, 0) = ? AND id != ?",
+		queueNameKey(name), courseID, exceptID).Scan(&count)
+	return count > 0, err
+}
+
+// Проверка названия и его уникальности в пределах курса одним вызовом
+func checkQueueName(name string, courseID, exceptID int) (string, error) {
+	name, err := validateQueueName(name)
+	if err != nil {
+		return "", err
+	}
+	taken, err := queueNameTaken(name, courseID, exceptID)
+	if err != nil {
+		return "", err
+	}
+	if taken {
+		return "", errQueueNameTaken
+	}
+	return name, nil
+}
+
+// Переименование выбранной администратором очереди
+func handleQueueRename(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID, exists := selectedQueueID[chatID]
+	if !exists {
+		msg := tgbotapi.NewMessage(chatID, "Ошибка: очередь не выбрана.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		delete(userStates, chatID)
+		return
+	}
+
+	if message.Text == "Назад в главное меню" {
+		delete(userStates, chatID)
+		delete(selectedQueueID, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	// Названия уникальны в пределах курса очереди
+	q, err := loadQueue(queueID)
+	var name string
+	if err == nil {
+		name, err = checkQueueName(message.Text, q.CourseID, queueID)
+	}
+	if err != nil {
+		if !isQueueNameError(err) {
+			log.Printf("Ошибка проверки названия очереди: %v", err)
+			err = errors.New("Ошибка при переименовании очереди.")
+		}
+		msg := tgbotapi.NewMessage(chatID, err.Error()+"\nВведите другое название:")
+		send(bot, msg)
+		return
+	}
+
+	_, err = db.Exec("UPDATE queues SET name = ?, name_key = ? WHERE id = ?", name, queueNameKey(name), queueID)
+	if err = queueNameConflict(err); errors.Is(err, errQueueNameTaken) {
+		send(bot, tgbotapi.NewMessage(chatID, err.Error()+"\nВведите другое название:"))
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка переименования очереди: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при переименовании очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		delete(userStates, chatID)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Очередь переименована в \"%s\".", name))
+	send(bot, msg)
+	adminQueueMenu(bot, chatID, queueID)
+}
+
+// Нарушение уникального индекса названий: очередь с таким названием
+// успели создать между проверкой и записью
+func queueNameConflict(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return errQueueNameTaken
+	}
+	return err
+}
+
+// Ошибки валидации показываются пользователю как есть, остальные — только в лог
+func isQueueNameError(err error) bool {
+	for _, e := range []error{errQueueNameTooShort, errQueueNameTooLong, errQueueNameNoText, errQueueNameCommand, errQueueNameTaken} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestQueueNameWithSuffix(t *testing.T) {
+	long := strings.Repeat("я", maxQueueNameLen)
+	tests := []struct {
+		name, suffix, want string
+	}{
+		{"Лаба", " (12)", "Лаба (12)"},
+		{"  Лаба   ОС ", " (3)", "Лаба ОС (3)"},
+		{long, " (7)", strings.Repeat("я", maxQueueNameLen-4) + " (7)"},
+		{strings.Repeat("я", maxQueueNameLen-5) + " ab", " (7)", strings.Repeat("я", maxQueueNameLen-5) + " (7)"},
+	}
+	for _, tt := range tests {
+		got := queueNameWithSuffix(tt.name, tt.suffix)
+		if got != tt.want {
+			t.Errorf("queueNameWithSuffix(%q, %q) = %q, want %q", tt.name, tt.suffix, got, tt.want)
+		}
+		if n := utf8.RuneCountInString(got); n > maxQueueNameLen {
+			t.Errorf("queueNameWithSuffix(%q, %q): длина %d больше лимита", tt.name, tt.suffix, n)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const notifyInterval = 2 * time.Second
+
+// Фоновая рассылка: уведомления о месте и всё, что накопилось в outbox.
+// Неотправленное хранится в базе и досылается после перезапуска.
+func runNotifier(bot *tgbotapi.BotAPI) {
+	ticker := time.NewTicker(notifyInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := checkPositionNotifications(); err != nil {
+			log.Printf("Ошибка проверки мест в очередях: %v", err)
+		}
+		if err := deliverOutbox(bot, time.Now()); err != nil {
+			log.Printf("Ошибка рассылки: %v", err)
+		}
+		if err := finishOutboxJobs(bot); err != nil {
+			log.Printf("Ошибка завершения рассылок: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// Разбор мест для уведомлений: "5, 3, 2". Первое место — это вызов,
+// о нём сообщается всегда.
+func parseNotifyPositions(text string) ([]int, error) {
+	var positions []int
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == ';' }) {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 2 || n > 100 {
+			return nil, fmt.Errorf("не понимаю место %q, нужны числа от 2 до 100", part)
+		}
+		if !slices.Contains(positions, n) {
+			positions = append(positions, n)
+		}
+	}
+	slices.Sort(positions)
+	return positions, nil
+}
+
+func formatNotifyPositions(positions []int) string {
+	parts := make([]string, len(positions))
+	for i, n := range positions {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Поиск тех, кто продвинулся до очередного порога, и постановка уведомлений.
+// В notified_position хранится последний порог, о котором сообщили.
+func checkPositionNotifications() error {
+	rows, err := db.Query(`
+	SELECT e.id, e.queue_id, e.user_id, e.notified_position, COALESCE(u.notify, 0), q.name, q.notify_positions
+	FROM queue_entries e
+	JOIN queues q ON q.id = e.queue_id
+	LEFT JOIN users u ON u.user_id = e.user_id
+	WHERE q.status = ? AND q.notify_positions != ''
+	ORDER BY e.queue_id, e.position, e.id`, queueStatusOpen)
+	if err != nil {
+		return err
+	}
+
+	type crossing struct {
+		entryID   int
+		userID    int64
+		threshold int
+		position  int
+		queueName string
+		notify    bool
+	}
+	var changes []crossing
+	lastQueue, position := 0, 0
+	for rows.Next() {
+		var c crossing
+		var queueID, notified int
+		var positions string
+		if err := rows.Scan(&c.entryID, &queueID, &c.userID, &notified, &c.notify, &c.queueName, &positions); err != nil {
+			rows.Close()
+			return err
+		}
+		if queueID != lastQueue {
+			lastQueue, position = queueID, 0
+		}
+		position++
+
+		// Ближайший порог, до которого участник уже дошёл
+		thresholds, _ := parseNotifyPositions(positions)
+		idx := slices.IndexFunc(thresholds, func(t int) bool { return t >= position })
+		if idx >= 0 {
+			c.threshold = thresholds[idx]
+		}
+		if c.threshold == notified {
+			continue
+		}
+		// Сообщаем только о продвижении вперёд; при сдвиге назад просто запоминаем порог
+		c.notify = c.notify && c.userID != 0 && position > 1 && c.threshold != 0 && (notified == 0 || c.threshold < notified)
+		c.position = position
+		changes = append(changes, c)
+	}
+	rows.Close()
+
+	for _, c := range changes {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE queue_entries SET notified_position = ? WHERE id = ?", c.threshold, c.entryID); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if c.notify {
+			text := fmt.Sprintf("Очередь \"%s\": вы на %d месте.", c.queueName, c.position)
+			if c.position == 2 {
+				text = fmt.Sprintf("Очередь \"%s\": перед вами остался один человек, готовьтесь!", c.queueName)
+			}
+			if err := enqueueMessage(tx, c.userID, text); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Включение и выключение уведомлений о месте в очереди
+func toggleNotifications(bot *tgbotapi.BotAPI, chatID int64) {
+	var notify bool
+	err := db.QueryRow("UPDATE users SET notify = NOT notify WHERE user_id = ? RETURNING notify", chatID).Scan(&notify)
+	if err != nil {
+		log.Printf("Ошибка переключения уведомлений: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при изменении настроек уведомлений.")
+		send(bot, msg)
+		return
+	}
+
+	text := "Уведомления выключены."
+	if notify {
+		text = "Уведомления включены: бот напишет, когда вы приблизитесь к началу очереди."
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = mainMenu()
+	send(bot, msg)
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	orderFIFO     = "fifo"
+	orderRandom   = "random"
+	orderPriority = "priority"
+
+	// За какой период учитываются сдачи при порядке "приоритет"
+	priorityWindow = 14 * 24 * time.Hour
+)
+
+var orderPolicyNames = map[string]string{
+	orderFIFO:     "по времени записи",
+	orderRandom:   "случайный",
+	orderPriority: "приоритет",
+}
+
+// Запись в очереди
+type QueueEntry struct {
+	ID       int
+	QueueID  int
+	UserID   int64
+	Username string
+	JoinedAt time.Time
+	Position int
+	Priority int   // уровень приоритета, назначенный преподавателем; 0 — обычный
+	Defers   int   // сколько раз участник пропускал других вперёд
+	CalledBy int64 // кто вызвал участника, 0 — не вызван
+	Labs     []int // какие лабы сдаёт
+}
+
+const (
+	entryColumns = "id, queue_id, user_id, username, joined_at, position, priority, defers, called_by, labs"
+	maxPriority  = 3
+)
+
+func scanEntry(row interface{ Scan(...any) error }) (QueueEntry, error) {
+	var e QueueEntry
+	var username sql.NullString
+	var calledBy sql.NullInt64
+	var labs string
+	err := row.Scan(&e.ID, &e.QueueID, &e.UserID, &username, &e.JoinedAt, &e.Position, &e.Priority, &e.Defers, &calledBy, &labs)
+	e.Username = username.String
+	e.CalledBy = calledBy.Int64
+	e.Labs, _ = parseLabList(labs)
+	return e, err
+}
+
+// Записи очереди в действующем порядке
+func loadEntries(queueID int) ([]QueueEntry, error) {
+	rows, err := db.Query("SELECT "+entryColumns+" FROM queue_entries WHERE queue_id = ? ORDER BY position, id", queueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []QueueEntry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Можно ли записаться в ещё не открытую очередь.
+// При случайном порядке запись до открытия — это и есть жеребьёвка.
+func allowsPreRegistration(q Queue) bool {
+	return q.Status == queueStatusDraft && q.OrderPolicy == orderRandom
+}
+
+// Добавление записи с учётом порядка очереди
+func insertQueueEntry(q Queue, userID int64, username string, labs []int) error {
+	return insertQueueEntryAt(q, userID, username, labs, -1)
+}
+
+// Добавление записи на место index (с нуля).
+// При index < 0 место определяется порядком очереди.
+func insertQueueEntryAt(q Queue, userID int64, username string, labs []int, index int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	counts, err := entryServedCounts(tx, q.ID)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index > len(counts) {
+		index = len(counts)
+		own := 0
+		if q.OrderPolicy == orderPriority {
+			if own, err = recentServedCount(tx, userID); err != nil {
+				return err
+			}
+		}
+		// Новая запись идёт после всех с назначенным приоритетом,
+		// а при порядке "приоритет" — ещё и перед теми, кого принимали чаще
+		for i, c := range counts {
+			if c.priority == 0 && q.OrderPolicy == orderPriority && c.served > own {
+				index = i
+				break
+			}
+		}
+	}
+
+	res, err := tx.Exec("INSERT INTO queue_entries (queue_id, user_id, username, labs) VALUES (?, ?, ?, ?)",
+		q.ID, userID, username, formatLabList(labs))
+	if err != nil {
+		return err
+	}
+	entryID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(counts)+1)
+	for _, c := range counts {
+		ids = append(ids, c.entryID)
+	}
+	ids = slices.Insert(ids, index, int(entryID))
+	if err := setEntryOrder(tx, ids); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Перенумерация записей очереди в заданном порядке
+func setEntryOrder(tx *sql.Tx, ids []int) error {
+	for i, id := range ids {
+		if _, err := tx.Exec("UPDATE queue_entries SET position = ? WHERE id = ?", i+1, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Перемещение записи на место index (с нуля), остальные сдвигаются
+func moveEntry(queueID, entryID, index int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	counts, err := entryServedCounts(tx, queueID)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for _, c := range counts {
+		if c.entryID != entryID {
+			ids = append(ids, c.entryID)
+		}
+	}
+	if len(ids) == len(counts) {
+		return sql.ErrNoRows
+	}
+	index = max(0, min(index, len(ids)))
+	if err := setEntryOrder(tx, slices.Insert(ids, index, entryID)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Назначение приоритета: запись встаёт за всеми с таким же или большим
+// приоритетом, но перед теми, у кого он меньше.
+func setEntryPriority(queueID, entryID, priority int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE queue_entries SET priority = ? WHERE id = ? AND queue_id = ?", priority, entryID, queueID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	counts, err := entryServedCounts(tx, queueID)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	index := -1
+	for _, c := range counts {
+		if c.entryID == entryID {
+			continue
+		}
+		if index < 0 && c.priority < priority {
+			index = len(ids)
+		}
+		ids = append(ids, c.entryID)
+	}
+	if index < 0 {
+		index = len(ids)
+	}
+	if err := setEntryOrder(tx, slices.Insert(ids, index, entryID)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type entryServed struct {
+	entryID  int
+	userID   int64
+	position int
+	priority int
+	served   int
+}
+
+// Общие методы *sql.DB и *sql.Tx
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Число недавних сдач для каждой записи очереди, в порядке очереди
+func entryServedCounts(tx queryer, queueID int) ([]entryServed, error) {
+	rows, err := tx.Query(`
+	SELECT e.id, e.user_id, e.position, e.priority,
+		(SELECT COUNT(*) FROM served_entries s WHERE s.user_id = e.user_id AND s.served_at >= ?)
+	FROM queue_entries e WHERE e.queue_id = ? ORDER BY e.position, e.id`,
+		time.Now().Add(-priorityWindow).UTC(), queueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []entryServed
+	for rows.Next() {
+		var c entryServed
+		if err := rows.Scan(&c.entryID, &c.userID, &c.position, &c.priority, &c.served); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+func recentServedCount(tx queryer, userID int64) (int, error) {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM served_entries WHERE user_id = ? AND served_at >= ?",
+		userID, time.Now().Add(-priorityWindow).UTC()).Scan(&count)
+	return count, err
+}
+
+// Перемешивание очереди со случайным порядком в момент открытия
+func shuffleQueue(queueID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var policy string
+	if err := tx.QueryRow("SELECT order_policy FROM queues WHERE id = ?", queueID).Scan(&policy); err != nil {
+		return err
+	}
+	if policy != orderRandom {
+		return nil
+	}
+
+	counts, err := entryServedCounts(tx, queueID)
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(counts, func(a, b entryServed) int { return b.priority - a.priority })
+
+	// Перемешиваем внутри каждого уровня приоритета
+	var ids []int
+	for start := 0; start < len(counts); {
+		end := start
+		for end < len(counts) && counts[end].priority == counts[start].priority {
+			end++
+		}
+		group := make([]int, 0, end-start)
+		for _, c := range counts[start:end] {
+			group = append(group, c.entryID)
+		}
+		rand.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		ids = append(ids, group...)
+		start = end
+	}
+	if err := setEntryOrder(tx, ids); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Пояснение к порядку очереди для showQueueEntries
+func describeOrder(q Queue) string {
+	switch q.OrderPolicy {
+	case orderRandom:
+		if q.Status == queueStatusOpen {
+			return "Порядок: случайный, очередь перемешана при открытии, дальше — по времени записи."
+		}
+		return "Порядок: случайный. До открытия можно записаться, при открытии очередь будет перемешана."
+	case orderPriority:
+		return fmt.Sprintf("Порядок: первыми идут те, кого реже принимали за последние %d дней (в скобках — число сдач), при равенстве — по времени записи.",
+			int(priorityWindow.Hours()/24))
+	}
+	return "Порядок: по времени записи."
+}
+
+// Приём первого в очереди: запись переносится в историю.
+// Возвращает принятого и следующего за ним; nil, если их нет.
+func serveNextEntry(queueID int) (served, next *QueueEntry, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	head := func() (*QueueEntry, error) {
+		e, err := scanEntry(tx.QueryRow("SELECT "+entryColumns+" FROM queue_entries WHERE queue_id = ? ORDER BY position, id LIMIT 1", queueID))
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return &e, err
+	}
+
+	if served, err = head(); err != nil || served == nil {
+		return nil, nil, err
+	}
+
+	_, err = tx.Exec("INSERT INTO served_entries (queue_id, user_id, username, joined_at, served_at, labs) VALUES (?, ?, ?, ?, ?, ?)",
+		queueID, served.UserID, served.Username, served.JoinedAt, time.Now().UTC(), formatLabList(served.Labs))
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = tx.Exec("DELETE FROM queue_entries WHERE id = ?", served.ID); err != nil {
+		return nil, nil, err
+	}
+	if next, err = head(); err != nil {
+		return nil, nil, err
+	}
+	return served, next, tx.Commit()
+}
+
+// Подпись участника в списке очереди
+func entryLabel(e QueueEntry) string {
+	if e.Username == "" {
+		return fmt.Sprintf("id%d", e.UserID)
+	}
+	return "@" + strings.TrimPrefix(e.Username, "@")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	outboxBatch       = 50
+	outboxMaxAttempts = 5
+	outboxRetryDelay  = 30 * time.Second
+)
+
+// Общий метод *sql.DB и *sql.Tx для записи
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Постановка сообщения в очередь на отправку фоновым обработчиком
+func enqueueMessage(ex execer, chatID int64, text string) error {
+	_, err := ex.Exec("INSERT INTO outbox (chat_id, text, created_at) VALUES (?, ?, ?)", chatID, text, time.Now().UTC())
+	return err
+}
+
+// Рассылка одного текста многим получателям. Когда всё будет отправлено,
+// владелец (если он задан) получит отчёт: сколько доставлено и сколько нет.
+func enqueueBroadcast(ownerID int64, title string, chatIDs []int64, text string) (int, error) {
+	return enqueueJob(ownerID, title, chatIDs, text, 0, 0)
+}
+
+// Рассылка копии сообщения: так доходят фото, файлы и пересланные сообщения
+func enqueueCopyBroadcast(ownerID int64, title string, chatIDs []int64, fromChatID int64, messageID int) (int, error) {
+	return enqueueJob(ownerID, title, chatIDs, "", fromChatID, messageID)
+}
+
+func enqueueJob(ownerID int64, title string, chatIDs []int64, text string, fromChatID int64, messageID int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO outbox_jobs (owner_id, title, created_at) VALUES (?, ?, ?)", ownerID, title, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	jobID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for _, chatID := range chatIDs {
+		_, err := tx.Exec("INSERT INTO outbox (chat_id, text, from_chat_id, message_id, job_id, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			chatID, text, fromChatID, messageID, jobID, time.Now().UTC())
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int(jobID), tx.Commit()
+}
+
+type outboxMessage struct {
+	id         int
+	chatID     int64
+	text       string
+	fromChatID int64 // если задан, сообщение messageID копируется из этого чата
+	messageID  int
+	attempts   int
+}
+
+func (m outboxMessage) chattable() tgbotapi.Chattable {
+	if m.fromChatID != 0 {
+		return tgbotapi.NewCopyMessage(m.chatID, m.fromChatID, m.messageID)
+	}
+	return tgbotapi.NewMessage(m.chatID, m.text)
+}
+
+// Ошибки, после которых повторять бессмысленно: бот заблокирован, чат не найден
+func permanentSendError(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && (tgErr.Code == 400 || tgErr.Code == 403)
+}
+
+// Отправка накопившихся сообщений. Лимиты соблюдает send,
+// а при долгом retry_after сообщение откладывается в базе.
+func deliverOutbox(bot *tgbotapi.BotAPI, now time.Time) error {
+	rows, err := db.Query(`SELECT id, chat_id, text, from_chat_id, message_id, attempts FROM outbox
+	WHERE status = 'pending' AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
+	ORDER BY id LIMIT ?`, now.UTC(), outboxBatch)
+	if err != nil {
+		return err
+	}
+	var pending []outboxMessage
+	for rows.Next() {
+		var m outboxMessage
+		if err := rows.Scan(&m.id, &m.chatID, &m.text, &m.fromChatID, &m.messageID, &m.attempts); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, m)
+	}
+	rows.Close()
+
+	for _, m := range pending {
+		_, sendErr := send(bot, m.chattable())
+		if sendErr == nil {
+			if _, err := db.Exec("UPDATE outbox SET status = 'sent', sent_at = ? WHERE id = ?", time.Now().UTC(), m.id); err != nil {
+				return err
+			}
+			continue
+		}
+
+		status := "pending"
+		if m.attempts+1 >= outboxMaxAttempts || permanentSendError(sendErr) {
+			status = "failed"
+		}
+		delay := max(retryAfter(sendErr), time.Duration(m.attempts+1)*outboxRetryDelay)
+		_, err := db.Exec("UPDATE outbox SET attempts = attempts + 1, status = ?, error = ?, next_attempt_at = ? WHERE id = ?",
+			status, sendErr.Error(), time.Now().Add(delay).UTC(), m.id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Отчёт владельцу о завершённых рассылках
+func finishOutboxJobs(bot *tgbotapi.BotAPI) error {
+	rows, err := db.Query(`
+	SELECT j.id, j.owner_id, j.title,
+		COUNT(CASE WHEN o.status = 'sent' THEN 1 END),
+		COUNT(CASE WHEN o.status = 'failed' THEN 1 END)
+	FROM outbox_jobs j LEFT JOIN outbox o ON o.job_id = j.id
+	WHERE j.finished_at IS NULL
+	GROUP BY j.id
+	HAVING COUNT(CASE WHEN o.status = 'pending' THEN 1 END) = 0`)
+	if err != nil {
+		return err
+	}
+	type jobReport struct {
+		id           int
+		ownerID      int64
+		title        string
+		sent, failed int
+	}
+	var finished []jobReport
+	for rows.Next() {
+		var j jobReport
+		if err := rows.Scan(&j.id, &j.ownerID, &j.title, &j.sent, &j.failed); err != nil {
+			rows.Close()
+			return err
+		}
+		finished = append(finished, j)
+	}
+	rows.Close()
+
+	for _, j := range finished {
+		if _, err := db.Exec("UPDATE outbox_jobs SET finished_at = ? WHERE id = ?", time.Now().UTC(), j.id); err != nil {
+			return err
+		}
+		if j.ownerID != 0 {
+			send(bot, tgbotapi.NewMessage(j.ownerID, fmt.Sprintf("Рассылка «%s» завершена: доставлено %d, не доставлено %d.", j.title, j.sent, j.failed)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const queueListPageSize = 8
+
+// Состояние списка очередей: поиск, фильтр и страница
+type queueListView struct {
+	userID  int64 // видны только очереди курсов пользователя и общие
+	query   string
+	showAll bool // показывать закрытые и архивные
+	page    int
+}
+
+var queueListViews = make(map[int64]*queueListView) // userID -> список очередей
+
+// Показать список очередей для выбора. Свежие по активности — сверху.
+func showQueues(bot *tgbotapi.BotAPI, chatID int64) {
+	view := &queueListView{userID: chatID}
+	queueListViews[chatID] = view
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM queues q WHERE "+visibleQueueCondition, chatID).Scan(&total); err != nil {
+		log.Printf("Ошибка при загрузке очередей: %v", err)
+	}
+	if total == 0 {
+		msg := tgbotapi.NewMessage(chatID, "Очередей пока нет.")
+		send(bot, msg)
+		delete(userStates, chatID)
+		delete(queueActions, chatID)
+		delete(queueListViews, chatID)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Выберите очередь в списке или введите часть названия для поиска.")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+	)
+	send(bot, msg)
+	sendQueueList(bot, chatID, view)
+}
+
+func sendQueueList(bot *tgbotapi.BotAPI, chatID int64, view *queueListView) {
+	text, keyboard, err := renderQueueList(view)
+	if err != nil {
+		log.Printf("Ошибка при загрузке очередей: %v", err)
+		send(bot, tgbotapi.NewMessage(chatID, "Ошибка при загрузке очередей."))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	send(bot, msg)
+}
+
+// Текст и кнопки страницы списка
+func renderQueueList(view *queueListView) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	where := "WHERE " + visibleQueueCondition
+	args := []any{view.userID}
+	if !view.showAll {
+		where += " AND q.status IN (?, ?)"
+		args = append(args, queueStatusOpen, queueStatusDraft)
+	}
+	if view.query != "" {
+		where += " AND instr(q.name_key, ?) > 0"
+		args = append(args, queueNameKey(view.query))
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM queues q "+where, args...).Scan(&total); err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	pages := max(1, (total+queueListPageSize-1)/queueListPageSize)
+	view.page = max(0, min(view.page, pages-1))
+
+	// Активность — последняя запись в очередь или последний приём
+	rows, err := db.Query(`
+	SELECT q.id, q.name, q.status, q.session_at, COALESCE((SELECT c.name FROM courses c WHERE c.id = q.course_id), '')
+	FROM queues q `+where+`
+	ORDER BY MAX(
+		COALESCE(q.created_at, ''),
+		COALESCE((SELECT MAX(e.joined_at) FROM queue_entries e WHERE e.queue_id = q.id), ''),
+		COALESCE((SELECT MAX(s.served_at) FROM served_entries s WHERE s.queue_id = q.id), '')
+	) DESC, q.id DESC
+	LIMIT ? OFFSET ?`, append(args, queueListPageSize, view.page*queueListPageSize)...)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	defer rows.Close()
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	var sessions []queueSession
+	for rows.Next() {
+		var id int
+		var name, status, course string
+		var sessionAt sql.NullTime
+		if err := rows.Scan(&id, &name, &status, &sessionAt, &course); err != nil {
+			return "", tgbotapi.InlineKeyboardMarkup{}, err
+		}
+		label := name
+		if course != "" {
+			label += " · " + course
+		}
+		if sessionAt.Valid {
+			t := sessionAt.Time.In(location)
+			label += fmt.Sprintf(" · %s %s", weekdayNames[t.Weekday()], t.Format("02.01 15:04"))
+		}
+		sessions = append(sessions, queueSession{name: name, at: sessionAt})
+		if status != queueStatusOpen {
+			label += " (" + queueStatusNames[status] + ")"
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("qpick:%d", id)),
+		))
+	}
+	if err := rows.Err(); err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	var text string
+	switch {
+	case total == 0 && view.query != "":
+		text = fmt.Sprintf("По запросу «%s» ничего не найдено.", view.query)
+	case total == 0:
+		text = "Открытых очередей нет."
+	case view.query != "":
+		text = fmt.Sprintf("Очереди по запросу «%s»:", view.query)
+	default:
+		text = "Очереди:"
+	}
+	if pages > 1 {
+		text += fmt.Sprintf(" (страница %d из %d)", view.page+1, pages)
+	}
+	text += groupBySessionDate(sessions)
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if view.page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("« Назад", fmt.Sprintf("qpage:%d", view.page-1)))
+	}
+	if view.page < pages-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Вперёд »", fmt.Sprintf("qpage:%d", view.page+1)))
+	}
+	if len(nav) > 0 {
+		buttons = append(buttons, nav)
+	}
+
+	filter := tgbotapi.NewInlineKeyboardButtonData("Показать закрытые", "qall")
+	if view.showAll {
+		filter = tgbotapi.NewInlineKeyboardButtonData("Скрыть закрытые", "qall")
+	}
+	row := tgbotapi.NewInlineKeyboardRow(filter)
+	if view.query != "" {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("Сбросить поиск", "qreset"))
+	}
+	buttons = append(buttons, row)
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(buttons...), nil
+}
+
+// Очередь на странице списка и дата её занятия
+type queueSession struct {
+	name string
+	at   sql.NullTime
+}
+
+// Очереди страницы, сгруппированные по дням занятий. Пусто, если очередей
+// по расписанию на странице нет.
+func groupBySessionDate(sessions []queueSession) string {
+	if !slices.ContainsFunc(sessions, func(s queueSession) bool { return s.at.Valid }) {
+		return ""
+	}
+	sessions = slices.Clone(sessions)
+	slices.SortStableFunc(sessions, func(a, b queueSession) int {
+		switch {
+		case a.at.Valid != b.at.Valid:
+			if a.at.Valid {
+				return -1
+			}
+			return 1
+		case !a.at.Valid:
+			return 0
+		}
+		return a.at.Time.Compare(b.at.Time)
+	})
+
+	var groups strings.Builder
+	lastGroup := ""
+	for _, s := range sessions {
+		group := "Без даты"
+		if s.at.Valid {
+			group = formatSessionDate(s.at.Time)
+		}
+		if group != lastGroup {
+			fmt.Fprintf(&groups, "\n\n%s:", group)
+			lastGroup = group
+		}
+		if s.at.Valid {
+			fmt.Fprintf(&groups, "\n• %s (%s)", s.name, s.at.Time.In(location).Format("15:04"))
+		} else {
+			groups.WriteString("\n• " + s.name)
+		}
+	}
+	return groups.String()
+}
+
+// Поиск по тексту, введённому вместо выбора из списка
+func searchQueues(bot *tgbotapi.BotAPI, chatID int64, query string) {
+	view := queueListViews[chatID]
+	if view == nil {
+		view = &queueListView{userID: chatID}
+		queueListViews[chatID] = view
+	}
+	view.query = normalizeQueueName(query)
+	view.page = 0
+	sendQueueList(bot, chatID, view)
+}
+
+// Кнопки списка очередей: страницы, фильтр, сброс поиска и выбор
+func handleQueueListCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, action, arg string) string {
+	chatID := callbackQuery.From.ID
+	view := queueListViews[chatID]
+	if userStates[chatID] != "select_queue_for_action" || view == nil || callbackQuery.Message == nil {
+		return "Этот список уже не действует."
+	}
+	message := callbackQuery.Message
+
+	switch action {
+	case "qpage":
+		view.page, _ = strconv.Atoi(arg)
+	case "qall":
+		view.showAll = !view.showAll
+		view.page = 0
+	case "qreset":
+		view.query = ""
+		view.page = 0
+	case "qpick":
+		queueID, _ := strconv.Atoi(arg)
+		var name string
+		if err := db.QueryRow("SELECT name FROM queues WHERE id = ?", queueID).Scan(&name); err != nil {
+			return "Этой очереди больше нет."
+		}
+		// Очереди чужих курсов в списке не показываются, но id можно подставить в кнопку
+		visible, err := queueVisible(queueID, chatID)
+		if err != nil {
+			log.Printf("Ошибка проверки доступа к очереди: %v", err)
+			return "Ошибка при загрузке очередей."
+		}
+		if !visible {
+			return "Эта очередь вам недоступна."
+		}
+		send(bot, tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, fmt.Sprintf("Выбрана очередь «%s».", name)))
+		runQueueAction(bot, chatID, queueID, callbackQuery.From)
+		return name
+	}
+
+	text, keyboard, err := renderQueueList(view)
+	if err != nil {
+		log.Printf("Ошибка при загрузке очередей: %v", err)
+		return "Ошибка при загрузке очередей."
+	}
+	send(bot, tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, text, keyboard))
+	header, _, _ := strings.Cut(text, "\n")
+	return strings.TrimSuffix(header, ":")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestGroupBySessionDate(t *testing.T) {
+	at := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2026, 10, day, hour, 0, 0, 0, location), Valid: true}
+	}
+	tests := []struct {
+		name     string
+		sessions []queueSession
+		want     string
+	}{
+		{
+			name:     "без расписания группы не показываются",
+			sessions: []queueSession{{name: "Общая"}, {name: "Другая"}},
+			want:     "",
+		},
+		{
+			name: "по дням, очереди без даты в конце",
+			sessions: []queueSession{
+				{name: "Общая"},
+				{name: "Физика 20.10", at: at(20, 12)},
+				{name: "ОС 19.10", at: at(19, 10)},
+				{name: "Сети 20.10", at: at(20, 9)},
+			},
+			want: "\n\nпонедельник, 19.10:\n• ОС 19.10 (10:00)" +
+				"\n\nвторник, 20.10:\n• Сети 20.10 (09:00)\n• Физика 20.10 (12:00)" +
+				"\n\nБез даты:\n• Общая",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupBySessionDate(tt.sessions); got != tt.want {
+				t.Errorf("получено %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Очередь чужого курса нельзя выбрать подставленной кнопкой
+func TestQueuePickHiddenQueue(t *testing.T) {
+	setupTestDB(t)
+	bot, fake := newTestBot(t)
+	const user = 1
+	c, err := createCourse("Чужой курс", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := createTestQueue(t, "Закрытая")
+	if _, err := db.Exec("UPDATE queues SET course_id = ? WHERE id = ?", c.ID, q.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	userStates[user] = "select_queue_for_action"
+	queueActions[user] = "show"
+	queueListViews[user] = &queueListView{userID: user}
+	t.Cleanup(func() {
+		delete(userStates, user)
+		delete(queueActions, user)
+		delete(queueListViews, user)
+	})
+
+	callback := &tgbotapi.CallbackQuery{
+		From:    &tgbotapi.User{ID: user},
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: user}},
+	}
+	answer := handleQueueListCallback(bot, callback, "qpick", fmt.Sprint(q.ID))
+	if answer != "Эта очередь вам недоступна." {
+		t.Errorf("ответ %q", answer)
+	}
+	if texts := fake.messagesTo(user); len(texts) != 0 {
+		t.Errorf("пользователю отправлено %q", texts)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var movingEntryID = make(map[int64]int) // userID -> перемещаемая запись
+
+// Возврат в меню администратора с актуальным составом очереди
+func backToAdminMenu(bot *tgbotapi.BotAPI, chatID int64, queueID int, text string) {
+	userStates[chatID] = "admin_mode"
+	delete(movingEntryID, chatID)
+
+	if q, err := loadQueue(queueID); err == nil {
+		if entries, err := loadEntries(queueID); err == nil {
+			text += "\n\nСостав очереди:\n" + formatQueueEntries(q, entries)
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = adminMenuKeyboard()
+	send(bot, msg)
+}
+
+// Клавиатура со списком записей очереди
+func entriesKeyboard(entries []QueueEntry) tgbotapi.ReplyKeyboardMarkup {
+	var buttons [][]tgbotapi.KeyboardButton
+	for i, e := range entries {
+		buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(fmt.Sprintf("%d. %s", i+1, entryLabel(e))),
+		))
+	}
+	buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")))
+	return tgbotapi.NewReplyKeyboard(buttons...)
+}
+
+// Запись по тексту кнопки "N. @username"
+func entryFromButton(entries []QueueEntry, text string) (QueueEntry, bool) {
+	number, label, _ := strings.Cut(text, ". ")
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > len(entries) || entryLabel(entries[n-1]) != label {
+		return QueueEntry{}, false
+	}
+	return entries[n-1], true
+}
+
+// Поиск записи по username
+func findEntryByUsername(queueID int, username string) (QueueEntry, error) {
+	return scanEntry(db.QueryRow("SELECT "+entryColumns+" FROM queue_entries WHERE queue_id = ? AND username = ? COLLATE NOCASE ORDER BY position, id LIMIT 1",
+		queueID, strings.TrimPrefix(username, "@")))
+}
+
+// Начало перемещения: выбор участника
+func startMoveEntry(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при получении списка очереди.")
+		return
+	}
+	if len(entries) == 0 {
+		backToAdminMenu(bot, chatID, queueID, "Очередь пуста.")
+		return
+	}
+
+	userStates[chatID] = "admin_move_select"
+	msg := tgbotapi.NewMessage(chatID, "Кого переместить?")
+	msg.ReplyMarkup = entriesKeyboard(entries)
+	send(bot, msg)
+}
+
+func handleMoveSelect(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	if message.Text == "Назад в главное меню" {
+		backToAdminMenu(bot, chatID, queueID, "Перемещение отменено.")
+		return
+	}
+
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при получении списка очереди.")
+		return
+	}
+	e, ok := entryFromButton(entries, message.Text)
+	if !ok {
+		msg := tgbotapi.NewMessage(chatID, "Выберите участника на клавиатуре.")
+		msg.ReplyMarkup = entriesKeyboard(entries)
+		send(bot, msg)
+		return
+	}
+
+	userStates[chatID] = "admin_move_action"
+	movingEntryID[chatID] = e.ID
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Куда переместить %s?", entryLabel(e)))
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Выше"), tgbotapi.NewKeyboardButton("Ниже")),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("В начало"), tgbotapi.NewKeyboardButton("На позицию…")),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+	)
+	send(bot, msg)
+}
+
+func handleMoveAction(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+	entryID := movingEntryID[chatID]
+
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при получении списка очереди.")
+		return
+	}
+	current := -1
+	for i, e := range entries {
+		if e.ID == entryID {
+			current = i
+		}
+	}
+	if current < 0 {
+		backToAdminMenu(bot, chatID, queueID, "Этого участника уже нет в очереди.")
+		return
+	}
+
+	var index int
+	switch message.Text {
+	case "Выше":
+		index = current - 1
+	case "Ниже":
+		index = current + 1
+	case "В начало":
+		index = 0
+	case "На позицию…":
+		userStates[chatID] = "admin_move_position"
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите номер места (от 1 до %d):", len(entries)))
+		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		send(bot, msg)
+		return
+	case "Назад в главное меню":
+		backToAdminMenu(bot, chatID, queueID, "Перемещение отменено.")
+		return
+	default:
+		msg := tgbotapi.NewMessage(chatID, "Неверная команда. Используйте меню.")
+		send(bot, msg)
+		return
+	}
+
+	applyMove(bot, chatID, queueID, entryID, index)
+}
+
+func handleMovePosition(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	position, err := strconv.Atoi(strings.TrimSpace(message.Text))
+	if err != nil || position < 1 {
+		msg := tgbotapi.NewMessage(chatID, "Нужно целое положительное число. Попробуйте снова:")
+		send(bot, msg)
+		return
+	}
+	applyMove(bot, chatID, queueID, movingEntryID[chatID], position-1)
+}
+
+func applyMove(bot *tgbotapi.BotAPI, chatID int64, queueID, entryID, index int) {
+	if err := moveEntry(queueID, entryID, index); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Ошибка перемещения записи: %v", err)
+		}
+		backToAdminMenu(bot, chatID, queueID, "Не удалось переместить участника.")
+		return
+	}
+	backToAdminMenu(bot, chatID, queueID, "Готово.")
+}
+
+// Добавление участника администратором: "@username [позиция]"
+func handleInsertUser(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	fields := strings.Fields(message.Text)
+	if len(fields) == 0 || len(fields) > 2 {
+		msg := tgbotapi.NewMessage(chatID, "Нужно ввести username и, если нужно, место: @ivanov 3")
+		send(bot, msg)
+		return
+	}
+	username := strings.TrimPrefix(fields[0], "@")
+	index := -1
+	if len(fields) == 2 {
+		position, err := strconv.Atoi(fields[1])
+		if err != nil || position < 1 {
+			msg := tgbotapi.NewMessage(chatID, "Место — целое положительное число. Попробуйте снова:")
+			send(bot, msg)
+			return
+		}
+		index = position - 1
+	}
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при добавлении в очередь.")
+		return
+	}
+
+	// Если пользователь ещё не писал боту, уведомить его не получится
+	userID, err := findUserID(username)
+	if err != nil {
+		log.Printf("Ошибка поиска пользователя: %v", err)
+	}
+
+	if err := insertQueueEntryAt(q, userID, username, nil, index); err != nil {
+		log.Printf("Ошибка добавления в очередь: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при добавлении в очередь.")
+		return
+	}
+	if userID != 0 {
+		msg := tgbotapi.NewMessage(userID, fmt.Sprintf("Вас добавили в очередь \"%s\".", q.Name))
+		send(bot, msg)
+	}
+	backToAdminMenu(bot, chatID, queueID, fmt.Sprintf("@%s добавлен в очередь.", username))
+}
+
+// Назначение приоритета: "@username уровень"
+func handleSetPriority(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	fields := strings.Fields(message.Text)
+	priority := -1
+	if len(fields) == 2 {
+		if n, err := strconv.Atoi(fields[1]); err == nil && n >= 0 && n <= maxPriority {
+			priority = n
+		}
+	}
+	if priority < 0 {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Нужно ввести username и уровень от 0 до %d: @ivanov 1", maxPriority))
+		send(bot, msg)
+		return
+	}
+
+	e, err := findEntryByUsername(queueID, fields[0])
+	if err == sql.ErrNoRows {
+		backToAdminMenu(bot, chatID, queueID, "Пользователь не найден в этой очереди.")
+		return
+	}
+	if err == nil {
+		err = setEntryPriority(queueID, e.ID, priority)
+	}
+	if err != nil {
+		log.Printf("Ошибка назначения приоритета: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при назначении приоритета.")
+		return
+	}
+	backToAdminMenu(bot, chatID, queueID, fmt.Sprintf("Приоритет %s: %d.", entryLabel(e), priority))
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	resultAccepted = "accepted"
+	resultFixes    = "needs_fixes"
+	resultRejected = "rejected"
+)
+
+var resultNames = map[string]string{
+	resultAccepted: "принята",
+	resultFixes:    "на доработку",
+	resultRejected: "не принята",
+}
+
+var resultIcons = map[string]string{
+	resultAccepted: "✅",
+	resultFixes:    "🛠",
+	resultRejected: "❌",
+}
+
+// Кнопки оценки в порядке показа
+var resultButtons = []struct{ button, outcome string }{
+	{"Принята", resultAccepted},
+	{"На доработку", resultFixes},
+	{"Не принята", resultRejected},
+}
+
+// Оценка принятого студента: по одной лабе за раз
+type grading struct {
+	entry   QueueEntry
+	subject string
+	labs    []int // лабы, которые ещё не оценены; 0 — работа без номера
+	outcome string
+}
+
+var gradings = make(map[int64]*grading) // userID преподавателя -> текущая оценка
+
+// Начало оценки после приёма студента
+func startGrading(bot *tgbotapi.BotAPI, chatID int64, q Queue, served QueueEntry) {
+	g := &grading{entry: served, subject: q.Subject, labs: served.Labs}
+	if g.subject == "" {
+		g.subject = q.Name
+	}
+	if len(g.labs) == 0 {
+		g.labs = []int{0}
+	}
+	gradings[chatID] = g
+	askOutcome(bot, chatID)
+}
+
+func labName(lab int) string {
+	if lab == 0 {
+		return "работа"
+	}
+	return fmt.Sprintf("лаба %d", lab)
+}
+
+func askOutcome(bot *tgbotapi.BotAPI, chatID int64) {
+	g := gradings[chatID]
+	userStates[chatID] = "admin_result"
+
+	row := tgbotapi.NewKeyboardButtonRow()
+	for _, b := range resultButtons {
+		row = append(row, tgbotapi.NewKeyboardButton(b.button))
+	}
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Результат %s — %s:", entryLabel(g.entry), labName(g.labs[0])))
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(row,
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Без оценки")))
+	send(bot, msg)
+}
+
+func handleResultSelect(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+	g := gradings[chatID]
+	if g == nil {
+		backToAdminMenu(bot, chatID, queueID, "Оценка прервана.")
+		return
+	}
+
+	if message.Text == "Без оценки" {
+		delete(gradings, chatID)
+		backToAdminMenu(bot, chatID, queueID, "Результат не записан.")
+		return
+	}
+
+	g.outcome = ""
+	for _, b := range resultButtons {
+		if message.Text == b.button {
+			g.outcome = b.outcome
+		}
+	}
+	if g.outcome == "" {
+		msg := tgbotapi.NewMessage(chatID, "Выберите результат на клавиатуре.")
+		send(bot, msg)
+		return
+	}
+
+	userStates[chatID] = "admin_result_comment"
+	msg := tgbotapi.NewMessage(chatID, "Комментарий для студента:")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Без комментария")),
+	)
+	send(bot, msg)
+}
+
+func handleResultComment(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+	g := gradings[chatID]
+	if g == nil {
+		backToAdminMenu(bot, chatID, queueID, "Оценка прервана.")
+		return
+	}
+
+	comment := strings.TrimSpace(message.Text)
+	if comment == "Без комментария" {
+		comment = ""
+	}
+
+	lab := g.labs[0]
+	_, err := db.Exec(`INSERT INTO lab_results (queue_id, user_id, username, subject, lab, outcome, comment, graded_by, graded_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		queueID, g.entry.UserID, g.entry.Username, g.subject, lab, g.outcome, comment, chatID, time.Now().UTC())
+	if err != nil {
+		log.Printf("Ошибка записи результата: %v", err)
+		delete(gradings, chatID)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при записи результата.")
+		return
+	}
+
+	if g.entry.UserID != 0 {
+		text := fmt.Sprintf("%s, %s: %s.", g.subject, labName(lab), resultNames[g.outcome])
+		if comment != "" {
+			text += "\nКомментарий: " + comment
+		}
+		send(bot, tgbotapi.NewMessage(g.entry.UserID, text))
+	}
+
+	g.labs = g.labs[1:]
+	if len(g.labs) > 0 {
+		askOutcome(bot, chatID)
+		return
+	}
+	delete(gradings, chatID)
+	backToAdminMenu(bot, chatID, queueID, "Результат записан.")
+}
+
+// Сданные и несданные лабы студента по всем очередям.
+// Для каждой лабы учитывается последний результат.
+func showMyLabs(bot *tgbotapi.BotAPI, chatID int64) {
+	rows, err := db.Query(`SELECT subject, lab, outcome, comment FROM lab_results
+	WHERE user_id = ? ORDER BY graded_at, id`, chatID)
+	if err != nil {
+		log.Printf("Ошибка загрузки результатов: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при получении результатов.")
+		send(bot, msg)
+		return
+	}
+	defer rows.Close()
+
+	type labKey struct {
+		subject string
+		lab     int
+	}
+	type labResult struct{ outcome, comment string }
+	latest := make(map[labKey]labResult)
+	var subjects []string
+	labs := make(map[string][]int)
+	for rows.Next() {
+		var k labKey
+		var r labResult
+		if err := rows.Scan(&k.subject, &k.lab, &r.outcome, &r.comment); err != nil {
+			log.Printf("Ошибка загрузки результатов: %v", err)
+			continue
+		}
+		if _, ok := labs[k.subject]; !ok {
+			subjects = append(subjects, k.subject)
+		}
+		if _, ok := latest[k]; !ok {
+			labs[k.subject] = append(labs[k.subject], k.lab)
+		}
+		latest[k] = r
+	}
+
+	if len(subjects) == 0 {
+		msg := tgbotapi.NewMessage(chatID, "У вас пока нет оценённых лаб.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("Ваши лабы:")
+	for _, subject := range subjects {
+		b.WriteString("\n\n" + subject + ":")
+		for _, lab := range labs[subject] {
+			r := latest[labKey{subject, lab}]
+			b.WriteString(fmt.Sprintf("\n%s %s — %s", resultIcons[r.outcome], labName(lab), resultNames[r.outcome]))
+			if r.comment != "" && r.outcome != resultAccepted {
+				b.WriteString(": " + r.comment)
+			}
+		}
+	}
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	msg.ReplyMarkup = mainMenu()
+	send(bot, msg)
+}
@@ -0,0 +1,643 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Статусы заявок на вступление
+const (
+	joinRequestPending  = "pending"
+	joinRequestApproved = "approved"
+	joinRequestRejected = "rejected"
+)
+
+// *sql.DB или *sql.Tx
+type queryExecer interface {
+	queryer
+	execer
+}
+
+// Строка списка группы. Пока человек не назвался, user_id пустой.
+type RosterEntry struct {
+	ID       int
+	FullName string
+	Group    string // учебная группа, если указана в списке
+	Username string
+	UserID   int64
+}
+
+// Список ведётся на курс, если очередь принадлежит курсу, иначе на очередь.
+// Условие подставляется в запросы к roster вместе с rosterArgs.
+const rosterCondition = "(course_id = ? OR queue_id = ? AND course_id IS NULL)"
+
+func rosterArgs(q Queue) []any {
+	return []any{q.CourseID, q.ID}
+}
+
+// Ключ для сравнения ФИО: регистр, лишние пробелы и «ё» не важны
+func fullNameKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.Join(strings.Fields(name), " ")), "ё", "е")
+}
+
+// Ограничен ли состав очереди списком
+func hasRoster(q Queue) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM roster WHERE "+rosterCondition, rosterArgs(q)...).Scan(&n)
+	return n > 0, err
+}
+
+// Может ли пользователь записаться: списки выключены, списка нет или пользователь в нём
+func rosterAllows(q Queue, userID int64, username string) (bool, error) {
+	if !config.Features.Rosters {
+		return true, nil
+	}
+	restricted, err := hasRoster(q)
+	if err != nil || !restricted {
+		return true, err
+	}
+	return onRoster(q, userID, username)
+}
+
+// Есть ли пользователь в списке. Совпадение по username сразу
+// закрепляет строку списка за пользователем.
+func onRoster(q Queue, userID int64, username string) (bool, error) {
+	var id int
+	var boundTo sql.NullInt64
+	err := db.QueryRow("SELECT id, user_id FROM roster WHERE "+rosterCondition+
+		" AND (user_id = ? OR user_id IS NULL AND username != '' AND username = ? COLLATE NOCASE) ORDER BY user_id IS NULL LIMIT 1",
+		append(rosterArgs(q), userID, strings.TrimPrefix(username, "@"))...).Scan(&id, &boundTo)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !boundTo.Valid {
+		_, err = db.Exec("UPDATE roster SET user_id = ? WHERE id = ?", userID, id)
+	}
+	return true, err
+}
+
+// Добавление строк в список. Повторы по ФИО и группе пропускаются.
+func addRosterEntries(ex queryExecer, q Queue, entries []RosterEntry) (added int, err error) {
+	for _, e := range entries {
+		var n int
+		err := ex.QueryRow("SELECT COUNT(*) FROM roster WHERE "+rosterCondition+" AND name_key = ? AND study_group = ? COLLATE NOCASE",
+			append(rosterArgs(q), fullNameKey(e.FullName), e.Group)...).Scan(&n)
+		if err != nil {
+			return added, err
+		}
+		if n > 0 {
+			continue
+		}
+		if err := insertRosterEntry(ex, q, e); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
+
+func insertRosterEntry(ex execer, q Queue, e RosterEntry) error {
+	var courseID, queueID, userID any
+	if q.CourseID != 0 {
+		courseID = q.CourseID
+	} else {
+		queueID = q.ID
+	}
+	if e.UserID != 0 {
+		userID = e.UserID
+	}
+	_, err := ex.Exec("INSERT INTO roster (course_id, queue_id, full_name, name_key, study_group, username, user_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		courseID, queueID, e.FullName, fullNameKey(e.FullName), e.Group, strings.TrimPrefix(e.Username, "@"), userID)
+	return err
+}
+
+// Разбор строки списка: «Иванов Иван Иванович» или «Иванов Иван @ivanov»
+func parseRosterLine(line string) RosterEntry {
+	fields := strings.Fields(line)
+	var e RosterEntry
+	if n := len(fields); n > 1 && strings.HasPrefix(fields[n-1], "@") {
+		e.Username = strings.TrimPrefix(fields[n-1], "@")
+		fields = fields[:n-1]
+	}
+	e.FullName = strings.Join(fields, " ")
+	return e
+}
+
+func loadRoster(q Queue) ([]RosterEntry, error) {
+	rows, err := db.Query("SELECT id, full_name, study_group, username, COALESCE(user_id, 0) FROM roster WHERE "+rosterCondition+" ORDER BY study_group, name_key", rosterArgs(q)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []RosterEntry
+	for rows.Next() {
+		var e RosterEntry
+		if err := rows.Scan(&e.ID, &e.FullName, &e.Group, &e.Username, &e.UserID); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Кого нет в списке, просим назваться
+func startRosterClaim(bot *tgbotapi.BotAPI, chatID int64, q Queue) {
+	userStates[chatID] = "roster_claim"
+	selectedQueueID[chatID] = q.ID
+
+	msg := tgbotapi.NewMessage(chatID, "В эту очередь записываются только по списку группы, а вас в нём пока нет.\n"+
+		"Напишите фамилию и имя так, как они указаны в списке. Если вас в списке нет, преподаватель получит заявку.")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")))
+	send(bot, msg)
+}
+
+func handleRosterClaim(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	if message.Text == "Назад в главное меню" {
+		delete(userStates, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Запись отменена. Выберите действие:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	fullName := strings.Join(strings.Fields(message.Text), " ")
+	if len(strings.Fields(fullName)) < 2 {
+		send(bot, tgbotapi.NewMessage(chatID, "Напишите хотя бы фамилию и имя."))
+		return
+	}
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		delete(userStates, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при загрузке очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+	delete(userStates, chatID)
+
+	// Свободные строки с таким ФИО: пользователь подтверждает, что это он
+	var candidates []RosterEntry
+	roster, err := loadRoster(q)
+	if err != nil {
+		log.Printf("Ошибка поиска в списке группы: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при поиске в списке группы.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+	for _, e := range roster {
+		if e.UserID == 0 && fullNameKey(e.FullName) == fullNameKey(fullName) {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		createJoinRequest(bot, q, message.From, fullName)
+		return
+	}
+
+	rosterClaims[chatID] = rosterClaim{fullName: fullName, candidates: candidates}
+	userStates[chatID] = "roster_claim_confirm"
+
+	var buttons [][]tgbotapi.KeyboardButton
+	var found []string
+	for _, e := range candidates {
+		line := e.FullName
+		if e.Group != "" {
+			line += ", группа " + e.Group
+		}
+		found = append(found, line)
+		buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(claimButton(e, len(candidates)))))
+	}
+	buttons = append(buttons,
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Нет, это не я")),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+	)
+	msg := tgbotapi.NewMessage(chatID, "Нашёл в списке:\n"+strings.Join(found, "\n")+"\n\nЭто вы? Строка закрепится за вашим аккаунтом.")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
+	send(bot, msg)
+}
+
+type rosterClaim struct {
+	fullName   string
+	candidates []RosterEntry
+}
+
+var rosterClaims = make(map[int64]rosterClaim) // userID -> найденные в списке строки
+
+// Текст кнопки подтверждения; при тёзках различаем по группе
+func claimButton(e RosterEntry, total int) string {
+	if total == 1 {
+		return "Да, это я"
+	}
+	if e.Group == "" {
+		return "Это я, без группы"
+	}
+	return "Это я, группа " + e.Group
+}
+
+func handleRosterClaimConfirm(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+	claim := rosterClaims[chatID]
+
+	if message.Text == "Назад в главное меню" {
+		delete(userStates, chatID)
+		delete(rosterClaims, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Запись отменена. Выберите действие:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	var chosen *RosterEntry
+	for i, e := range claim.candidates {
+		if message.Text == claimButton(e, len(claim.candidates)) {
+			chosen = &claim.candidates[i]
+		}
+	}
+	if chosen == nil && message.Text != "Нет, это не я" {
+		send(bot, tgbotapi.NewMessage(chatID, "Выберите ответ на клавиатуре."))
+		return
+	}
+	delete(userStates, chatID)
+	delete(rosterClaims, chatID)
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при загрузке очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	// Тёзка из списка — не этот пользователь: решает преподаватель
+	if chosen == nil {
+		createJoinRequest(bot, q, message.From, claim.fullName)
+		return
+	}
+
+	res, err := db.Exec("UPDATE roster SET user_id = ? WHERE id = ? AND user_id IS NULL", chatID, chosen.ID)
+	if err != nil {
+		log.Printf("Ошибка закрепления строки списка: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при поиске в списке группы.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Строку успели занять: пусть разберётся преподаватель
+		createJoinRequest(bot, q, message.From, claim.fullName)
+		return
+	}
+	addUserToQueue(bot, chatID, queueID, message.From.UserName, nil)
+}
+
+// Заявка на вступление: уходит администраторам очереди
+func createJoinRequest(bot *tgbotapi.BotAPI, q Queue, user *tgbotapi.User, fullName string) {
+	chatID := user.ID
+
+	var pending int
+	err := db.QueryRow("SELECT COUNT(*) FROM join_requests WHERE queue_id = ? AND user_id = ? AND status = ?", q.ID, user.ID, joinRequestPending).Scan(&pending)
+	if err == nil && pending > 0 {
+		msg := tgbotapi.NewMessage(chatID, "Заявка уже отправлена, дождитесь решения преподавателя.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	var requestID int64
+	if err == nil {
+		var res sql.Result
+		res, err = db.Exec("INSERT INTO join_requests (queue_id, user_id, username, full_name, status, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			q.ID, user.ID, user.UserName, fullName, joinRequestPending, time.Now().UTC())
+		if err == nil {
+			requestID, err = res.LastInsertId()
+		}
+	}
+	var admins []int64
+	if err == nil {
+		admins, err = queueAdminIDs(q)
+	}
+	if err != nil {
+		log.Printf("Ошибка создания заявки на вступление: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при отправке заявки.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	text := fmt.Sprintf("Заявка на запись в очередь \"%s\": %s", q.Name, fullName)
+	if user.UserName != "" {
+		text += " (@" + user.UserName + ")"
+	}
+	text += "\nЭтого человека нет в списке группы. Одобрить — значит добавить в список."
+	for _, adminID := range admins {
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ReplyMarkup = joinRequestKeyboard(requestID)
+		send(bot, msg)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Вас не нашлось в списке группы. Заявка отправлена преподавателю — бот напишет, когда её рассмотрят.")
+	msg.ReplyMarkup = mainMenu()
+	send(bot, msg)
+}
+
+func joinRequestKeyboard(requestID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Одобрить", fmt.Sprintf("jr_approve:%d", requestID)),
+		tgbotapi.NewInlineKeyboardButtonData("Отклонить", fmt.Sprintf("jr_reject:%d", requestID)),
+	))
+}
+
+// Кому приходят заявки: администраторам курса, а для общей очереди —
+// администраторам бота или, если они не заданы, её создателю
+func queueAdminIDs(q Queue) ([]int64, error) {
+	if q.CourseID == 0 {
+		if len(config.Admins) > 0 {
+			return slices.Clone(config.Admins), nil
+		}
+		if q.CreatedBy == 0 {
+			return nil, nil
+		}
+		return []int64{q.CreatedBy}, nil
+	}
+
+	rows, err := db.Query("SELECT user_id FROM course_members WHERE course_id = ? AND role = ?", q.CourseID, courseRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Решение по заявке кнопкой «Одобрить» или «Отклонить»
+func decideJoinRequest(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, requestID int, approve bool) string {
+	var queueID int
+	var userID int64
+	var username, fullName, status string
+	err := db.QueryRow("SELECT queue_id, user_id, username, full_name, status FROM join_requests WHERE id = ?", requestID).
+		Scan(&queueID, &userID, &username, &fullName, &status)
+	if err == sql.ErrNoRows {
+		return "Заявка не найдена."
+	}
+	if err != nil {
+		log.Printf("Ошибка загрузки заявки: %v", err)
+		return "Ошибка при загрузке заявки."
+	}
+	if status != joinRequestPending {
+		return "Заявка уже рассмотрена."
+	}
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		return "Очередь больше недоступна."
+	}
+	if ok, err := canManageQueue(callbackQuery.From.ID, q); err != nil || !ok {
+		return "Недостаточно прав."
+	}
+
+	status = joinRequestRejected
+	if approve {
+		status = joinRequestApproved
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Ошибка решения по заявке: %v", err)
+		return "Ошибка при сохранении решения."
+	}
+	defer tx.Rollback()
+
+	// Решение принимается один раз, даже если несколько администраторов нажали одновременно
+	res, err := tx.Exec("UPDATE join_requests SET status = ?, decided_by = ?, decided_at = ? WHERE id = ? AND status = ?",
+		status, callbackQuery.From.ID, time.Now().UTC(), requestID, joinRequestPending)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			return "Заявка уже рассмотрена."
+		}
+	}
+	if err == nil && approve {
+		// Свободную строку с таким ФИО закрепляем за пользователем, иначе добавляем новую
+		res, err = tx.Exec("UPDATE roster SET user_id = ? WHERE id = (SELECT id FROM roster WHERE "+rosterCondition+" AND name_key = ? AND user_id IS NULL LIMIT 1)",
+			append(append([]any{userID}, rosterArgs(q)...), fullNameKey(fullName))...)
+		if err == nil {
+			if n, _ := res.RowsAffected(); n == 0 {
+				err = insertRosterEntry(tx, q, RosterEntry{FullName: fullName, Username: username, UserID: userID})
+			}
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Ошибка решения по заявке: %v", err)
+		return "Ошибка при сохранении решения."
+	}
+
+	if approve {
+		msg := tgbotapi.NewMessage(userID, fmt.Sprintf("Заявка одобрена: вы в списке группы очереди \"%s\".", q.Name))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Записаться", fmt.Sprintf("join:%d", q.ID)),
+		))
+		send(bot, msg)
+	} else {
+		send(bot, tgbotapi.NewMessage(userID, fmt.Sprintf("Заявка на запись в очередь \"%s\" отклонена.", q.Name)))
+	}
+
+	if callbackQuery.Message != nil {
+		verdict := "отклонена"
+		if approve {
+			verdict = "одобрена"
+		}
+		send(bot, tgbotapi.NewEditMessageText(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID,
+			fmt.Sprintf("%s\n\nЗаявка %s.", callbackQuery.Message.Text, verdict)))
+	}
+	if approve {
+		return "Заявка одобрена."
+	}
+	return "Заявка отклонена."
+}
+
+// Меню «Список группы» для администратора очереди
+func showRosterMenu(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	q, err := loadQueue(queueID)
+	var roster []RosterEntry
+	if err == nil {
+		roster, err = loadRoster(q)
+	}
+	if err != nil {
+		log.Printf("Ошибка загрузки списка группы: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при загрузке списка группы.")
+		return
+	}
+
+	scope := "очереди"
+	if q.CourseID != 0 {
+		scope = "курса (общий для всех его очередей)"
+	}
+	var text string
+	if len(roster) == 0 {
+		text = fmt.Sprintf("Список %s пуст — записаться может любой.", scope)
+	} else {
+		var lines []string
+		for i, e := range roster {
+			line := fmt.Sprintf("%d. %s", i+1, e.FullName)
+			if e.Group != "" {
+				line += ", " + e.Group
+			}
+			if e.Username != "" {
+				line += " @" + e.Username
+			}
+			if e.UserID != 0 {
+				line += " ✓"
+			}
+			lines = append(lines, line)
+		}
+		text = fmt.Sprintf("Список %s (✓ — уже в боте):\n%s\n\nЗаписаться могут только люди из списка.", scope, strings.Join(lines, "\n"))
+	}
+	text += "\nСписок можно загрузить файлом CSV или XLSX — просто пришлите его сюда."
+
+	var pending int
+	if err := db.QueryRow("SELECT COUNT(*) FROM join_requests WHERE queue_id = ? AND status = ?", queueID, joinRequestPending).Scan(&pending); err != nil {
+		log.Printf("Ошибка загрузки заявок: %v", err)
+	}
+
+	buttons := [][]tgbotapi.KeyboardButton{
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Добавить в список")),
+	}
+	if pending > 0 {
+		buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Заявки на вступление ("+strconv.Itoa(pending)+")")))
+	}
+	if len(roster) > 0 {
+		buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Очистить список")))
+	}
+	buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")))
+
+	userStates[chatID] = "roster_menu"
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
+	send(bot, msg)
+}
+
+func handleRosterMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	switch {
+	case message.Document != nil:
+		handleRosterFile(bot, message)
+	case message.Text == "Добавить в список":
+		userStates[chatID] = "roster_add"
+		msg := tgbotapi.NewMessage(chatID, "Отправьте список: каждый человек с новой строки, ФИО и, если известен, username в конце:\n"+
+			"Иванов Иван Иванович @ivanov\nПетрова Мария\n\n"+
+			"Или пришлите файл CSV/XLSX со столбцами «ФИО», «Группа» и, по желанию, «Username».")
+		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		send(bot, msg)
+	case strings.HasPrefix(message.Text, "Заявки на вступление"):
+		showJoinRequests(bot, chatID, queueID)
+		showRosterMenu(bot, chatID, queueID)
+	case message.Text == "Очистить список":
+		q, err := loadQueue(queueID)
+		if err == nil {
+			_, err = db.Exec("DELETE FROM roster WHERE "+rosterCondition, rosterArgs(q)...)
+		}
+		if err != nil {
+			log.Printf("Ошибка очистки списка группы: %v", err)
+			backToAdminMenu(bot, chatID, queueID, "Ошибка при очистке списка.")
+			return
+		}
+		backToAdminMenu(bot, chatID, queueID, "Список очищен, записаться снова может любой.")
+	case message.Text == "Назад в главное меню":
+		backToAdminMenu(bot, chatID, queueID, "Выберите действие:")
+	default:
+		send(bot, tgbotapi.NewMessage(chatID, "Выберите действие на клавиатуре."))
+	}
+}
+
+func handleRosterAdd(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	if message.Document != nil {
+		handleRosterFile(bot, message)
+		return
+	}
+
+	var entries []RosterEntry
+	for _, line := range strings.Split(message.Text, "\n") {
+		if e := parseRosterLine(line); e.FullName != "" {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		send(bot, tgbotapi.NewMessage(chatID, "Не нашёл ни одного ФИО. Отправьте список текстом, по человеку в строке."))
+		return
+	}
+
+	q, err := loadQueue(queueID)
+	var added int
+	if err == nil {
+		added, err = addRosterEntries(db, q, entries)
+	}
+	if err != nil {
+		log.Printf("Ошибка добавления в список группы: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при добавлении в список.")
+		return
+	}
+	send(bot, tgbotapi.NewMessage(chatID, fmt.Sprintf("Добавлено в список: %d, уже были в нём: %d.", added, len(entries)-added)))
+	showRosterMenu(bot, chatID, queueID)
+}
+
+// Повторная отправка кнопок по ожидающим заявкам
+func showJoinRequests(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	rows, err := db.Query("SELECT id, username, full_name FROM join_requests WHERE queue_id = ? AND status = ? ORDER BY id", queueID, joinRequestPending)
+	if err != nil {
+		log.Printf("Ошибка загрузки заявок: %v", err)
+		send(bot, tgbotapi.NewMessage(chatID, "Ошибка при загрузке заявок."))
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var username, fullName string
+		if err := rows.Scan(&id, &username, &fullName); err != nil {
+			log.Printf("Ошибка загрузки заявок: %v", err)
+			return
+		}
+		text := "Заявка: " + fullName
+		if username != "" {
+			text += " (@" + username + ")"
+		}
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = joinRequestKeyboard(id)
+		send(bot, msg)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	maxImportSize    = 1 << 20 // больше списку группы не нужно
+	maxImportErrors  = 10      // сколько ошибок показывать в предпросмотре
+	importNoGroupTag = "без группы"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{5,32}$`)
+
+var rosterImports = make(map[int64][]RosterEntry) // userID -> проверенные строки до подтверждения
+
+// Заголовки столбцов, которые узнаём в первой строке
+var rosterHeaders = map[string]string{
+	"фио":                  "name",
+	"ф.и.о.":               "name",
+	"ф. и. о.":             "name",
+	"фамилия имя отчество": "name",
+	"фамилия и имя":        "name",
+	"студент":              "name",
+	"name":                 "name",
+	"full name":            "name",
+	"фамилия":              "last",
+	"имя":                  "first",
+	"отчество":             "middle",
+	"группа":               "group",
+	"group":                "group",
+	"username":             "username",
+	"telegram":             "username",
+	"телеграм":             "username",
+	"ник":                  "username",
+}
+
+// Чтение таблицы из CSV (разделитель «;», «,» или табуляция) или XLSX
+func readSpreadsheet(fileName string, data []byte) ([][]string, error) {
+	if strings.HasSuffix(strings.ToLower(fileName), ".xlsx") || bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return readXLSX(data)
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, errors.New("файл не в кодировке UTF-8 — сохраните его как «CSV UTF-8» или XLSX")
+	}
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	delimiter := ','
+	for _, d := range []rune{';', '\t'} {
+		if bytes.Count(firstLine, []byte(string(d))) > bytes.Count(firstLine, []byte(string(delimiter))) {
+			delimiter = d
+		}
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать CSV: %v", err)
+	}
+	return rows, nil
+}
+
+// Разбор таблицы в строки списка. Ошибочные строки пропускаются
+// и описываются в errs с номерами строк таблицы.
+func parseRosterTable(rows [][]string) (entries []RosterEntry, errs []string) {
+	columns := map[string]int{"name": 0, "group": 1, "username": 2}
+	start := 0
+	if len(rows) > 0 {
+		header := make(map[string]int)
+		for i, cell := range rows[0] {
+			if field, ok := rosterHeaders[strings.ToLower(strings.Join(strings.Fields(cell), " "))]; ok {
+				header[field] = i
+			}
+		}
+		if len(header) > 0 {
+			columns, start = header, 1
+		}
+	}
+	cell := func(row []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.Join(strings.Fields(row[i]), " ")
+	}
+
+	seen := make(map[string]int) // ФИО и группа -> номер строки
+	for i := start; i < len(rows); i++ {
+		row, line := rows[i], i+1
+		if !slices.ContainsFunc(row, func(c string) bool { return strings.TrimSpace(c) != "" }) {
+			continue
+		}
+
+		e := RosterEntry{
+			FullName: cell(row, "name"),
+			Group:    cell(row, "group"),
+			Username: strings.TrimPrefix(cell(row, "username"), "@"),
+		}
+		if e.FullName == "" {
+			e.FullName = strings.Join(strings.Fields(cell(row, "last")+" "+cell(row, "first")+" "+cell(row, "middle")), " ")
+		}
+
+		var problem string
+		switch key := fullNameKey(e.FullName) + "\x00" + strings.ToLower(e.Group); {
+		case e.FullName == "":
+			problem = "нет ФИО"
+		case len(strings.Fields(e.FullName)) < 2:
+			problem = fmt.Sprintf("«%s» — нужны хотя бы фамилия и имя", e.FullName)
+		case e.Username != "" && !usernamePattern.MatchString(e.Username):
+			problem = fmt.Sprintf("«%s» — не похоже на username в Telegram", e.Username)
+		case seen[key] != 0:
+			problem = fmt.Sprintf("%s повторяет строку %d", e.FullName, seen[key])
+		default:
+			seen[key] = line
+			entries = append(entries, e)
+			continue
+		}
+		errs = append(errs, fmt.Sprintf("Строка %d: %s", line, problem))
+	}
+	return entries, errs
+}
+
+// Скачивание документа из Telegram
+func downloadDocument(bot *tgbotapi.BotAPI, doc *tgbotapi.Document) ([]byte, error) {
+	url, err := bot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := bot.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("скачивание файла: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImportSize+1))
+}
+
+// Файл со списком группы: разбор и предпросмотр перед импортом
+func handleRosterFile(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	doc := message.Document
+	if doc.FileSize > maxImportSize {
+		send(bot, tgbotapi.NewMessage(chatID, "Файл слишком большой: список группы должен весить не больше 1 МБ."))
+		return
+	}
+	data, err := downloadDocument(bot, doc)
+	if err != nil {
+		log.Printf("Ошибка скачивания файла: %v", err)
+		send(bot, tgbotapi.NewMessage(chatID, "Не удалось скачать файл, попробуйте ещё раз."))
+		return
+	}
+	if len(data) > maxImportSize {
+		send(bot, tgbotapi.NewMessage(chatID, "Файл слишком большой: список группы должен весить не больше 1 МБ."))
+		return
+	}
+
+	rows, err := readSpreadsheet(doc.FileName, data)
+	if err != nil {
+		send(bot, tgbotapi.NewMessage(chatID, "Не получилось прочитать файл: "+err.Error()+"."))
+		return
+	}
+	entries, errs := parseRosterTable(rows)
+	if len(entries) == 0 && len(errs) == 0 {
+		send(bot, tgbotapi.NewMessage(chatID, "В файле нет ни одной заполненной строки."))
+		return
+	}
+
+	text := fmt.Sprintf("Файл «%s» прочитан.\nГотово к импорту: %d, строк с ошибками: %d.", doc.FileName, len(entries), len(errs))
+	if len(entries) > 0 {
+		counts := make(map[string]int)
+		var groups []string
+		for _, e := range entries {
+			group := e.Group
+			if group == "" {
+				group = importNoGroupTag
+			}
+			if counts[group] == 0 {
+				groups = append(groups, group)
+			}
+			counts[group]++
+		}
+		slices.Sort(groups)
+		var parts []string
+		for _, g := range groups {
+			parts = append(parts, fmt.Sprintf("%s — %d", g, counts[g]))
+		}
+		text += "\nГруппы: " + strings.Join(parts, ", ")
+	}
+	if len(errs) > 0 {
+		text += "\n\nСтроки с ошибками не будут импортированы:\n" + strings.Join(errs[:min(len(errs), maxImportErrors)], "\n")
+		if len(errs) > maxImportErrors {
+			text += fmt.Sprintf("\n…и ещё %d", len(errs)-maxImportErrors)
+		}
+	}
+
+	buttons := [][]tgbotapi.KeyboardButton{}
+	if len(entries) > 0 {
+		rosterImports[chatID] = entries
+		userStates[chatID] = "roster_import_confirm"
+		text += "\n\nМожно импортировать или исправить файл и прислать его заново."
+		buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(fmt.Sprintf("Импортировать (%d)", len(entries)))))
+	}
+	buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")))
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
+	send(bot, msg)
+}
+
+func handleRosterImportConfirm(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	if message.Document != nil {
+		handleRosterFile(bot, message)
+		return
+	}
+	entries := rosterImports[chatID]
+	if !strings.HasPrefix(message.Text, "Импортировать") || len(entries) == 0 {
+		delete(rosterImports, chatID)
+		showRosterMenu(bot, chatID, queueID)
+		return
+	}
+	delete(rosterImports, chatID)
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при загрузке очереди.")
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Ошибка импорта списка группы: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при импорте списка.")
+		return
+	}
+	defer tx.Rollback()
+	added, err := addRosterEntries(tx, q, entries)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Ошибка импорта списка группы: %v", err)
+		backToAdminMenu(bot, chatID, queueID, "Ошибка при импорте списка, ничего не добавлено.")
+		return
+	}
+
+	send(bot, tgbotapi.NewMessage(chatID, fmt.Sprintf("Импортировано: %d, уже были в списке: %d.\nСтуденты найдут себя в списке по ФИО при записи в очередь.", added, len(entries)-added)))
+	showRosterMenu(bot, chatID, queueID)
+}
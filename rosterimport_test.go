@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRosterTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     [][]string
+		want     []RosterEntry
+		wantErrs []string
+	}{
+		{
+			name: "без заголовка: ФИО, группа, username",
+			rows: [][]string{{"Иванов  Иван", "ИВТ-21", "@ivan_ov"}, {"Петрова Мария"}},
+			want: []RosterEntry{
+				{FullName: "Иванов Иван", Group: "ИВТ-21", Username: "ivan_ov"},
+				{FullName: "Петрова Мария"},
+			},
+		},
+		{
+			name: "заголовок в другом порядке",
+			rows: [][]string{{"Группа", " ФИО ", "Telegram"}, {"ИВТ-22", "Сидоров Пётр", "sidorov"}},
+			want: []RosterEntry{{FullName: "Сидоров Пётр", Group: "ИВТ-22", Username: "sidorov"}},
+		},
+		{
+			name: "фамилия, имя и отчество в разных столбцах",
+			rows: [][]string{{"Фамилия", "Имя", "Отчество"}, {"Кузнецова", "Анна", "Сергеевна"}, {"Орлов", "Олег", ""}},
+			want: []RosterEntry{{FullName: "Кузнецова Анна Сергеевна"}, {FullName: "Орлов Олег"}},
+		},
+		{
+			name: "пустые строки пропускаются",
+			rows: [][]string{{"ФИО"}, {"", " "}, nil, {"Иванов Иван"}},
+			want: []RosterEntry{{FullName: "Иванов Иван"}},
+		},
+		{
+			name: "ошибки с номерами строк",
+			rows: [][]string{
+				{"ФИО", "Группа", "Username"},
+				{"Иванов Иван", "А", ""},
+				{"", "А", "nobody"},
+				{"Петров", "А", ""},
+				{"Сидоров Сидор", "", "bad!"},
+				{"иванов  иван", "а", ""},
+				{"Иванов Иван", "Б", ""},
+			},
+			want: []RosterEntry{{FullName: "Иванов Иван", Group: "А"}, {FullName: "Иванов Иван", Group: "Б"}},
+			wantErrs: []string{
+				"Строка 3: нет ФИО",
+				"Строка 4: «Петров» — нужны хотя бы фамилия и имя",
+				"Строка 5: «bad!» — не похоже на username в Telegram",
+				"Строка 6: иванов иван повторяет строку 2",
+			},
+		},
+		{
+			name: "пустая таблица",
+			rows: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := parseRosterTable(tt.rows)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("строки: получено %+v, ожидалось %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("ошибки: получено %q, ожидалось %q", errs, tt.wantErrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+	_ "time/tzdata" // в образе alpine нет базы часовых поясов
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	defaultTimeZone   = "Europe/Moscow"
+	timeInputLayout   = "02.01.2006 15:04"
+	timeInputHint     = "ДД.ММ.ГГГГ ЧЧ:ММ"
+	schedulerInterval = 30 * time.Second
+)
+
+// Часовой пояс, в котором вводится и показывается время
+var location = time.UTC
+
+// Загрузка часового пояса из настроек
+func loadLocation() (*time.Location, error) {
+	return time.LoadLocation(config.TimeZone)
+}
+
+func parseTime(text string) (time.Time, error) {
+	return time.ParseInLocation(timeInputLayout, text, location)
+}
+
+func formatTime(t time.Time) string {
+	return t.In(location).Format(timeInputLayout)
+}
+
+// Фоновая проверка расписания очередей
+func runScheduler(bot *tgbotapi.BotAPI) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		runScheduledTasks(bot, time.Now())
+		<-ticker.C
+	}
+}
+
+func runScheduledTasks(bot *tgbotapi.BotAPI, now time.Time) {
+	if err := generateTemplateQueues(now); err != nil {
+		log.Printf("Ошибка создания очередей по шаблонам: %v", err)
+	}
+
+	// Открытие и закрытие срабатывают один раз: после перехода время сбрасывается
+	opened, err := switchScheduledQueues(now, "opens_at", queueStatusOpen)
+	if err != nil {
+		log.Printf("Ошибка открытия очередей по расписанию: %v", err)
+	}
+	for _, queueID := range opened {
+		queueStatusChanged(queueID, queueStatusOpen)
+	}
+
+	closed, err := switchScheduledQueues(now, "closes_at", queueStatusClosed)
+	if err != nil {
+		log.Printf("Ошибка закрытия очередей по расписанию: %v", err)
+	}
+	for _, queueID := range closed {
+		queueStatusChanged(queueID, queueStatusClosed)
+	}
+
+	if err := checkMissedCalls(bot, now); err != nil {
+		log.Printf("Ошибка проверки неявок: %v", err)
+	}
+
+	if err := expireSwapRequests(bot, now); err != nil {
+		log.Printf("Ошибка отмены просроченных обменов: %v", err)
+	}
+}
+
+// Перевод очередей, у которых наступило время column, в статус status.
+// Имя колонки передаётся только из кода.
+func switchScheduledQueues(now time.Time, column, status string) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM queues WHERE "+column+" IS NOT NULL AND "+column+" <= ?", now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	var switched []int
+	for _, id := range ids {
+		res, err := db.Exec("UPDATE queues SET status = ?, "+column+" = NULL WHERE id = ? AND status != ?", status, id, status)
+		if err != nil {
+			return switched, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			switched = append(switched, id)
+		} else {
+			db.Exec("UPDATE queues SET "+column+" = NULL WHERE id = ?", id)
+		}
+	}
+	return switched, nil
+}
+
+// Рассылка подписчикам и участникам очереди сообщения о смене статуса
+// Последствия смены статуса, по расписанию или вручную: при открытии очередь
+// перемешивается по её порядку, подписчики и группа получают объявление
+func queueStatusChanged(queueID int, status string) {
+	switch status {
+	case queueStatusOpen:
+		if err := shuffleQueue(queueID); err != nil {
+			log.Printf("Ошибка перемешивания очереди %d: %v", queueID, err)
+		}
+		announceQueueStatus(queueID, "Очередь \"%s\" открыта, можно записываться!")
+	case queueStatusClosed:
+		announceQueueStatus(queueID, "Очередь \"%s\" закрыта.")
+	}
+}
+
+func announceQueueStatus(queueID int, format string) {
+	var name string
+	var groupChatID int64
+	if err := db.QueryRow("SELECT name, COALESCE(group_chat_id, 0) FROM queues WHERE id = ?", queueID).Scan(&name, &groupChatID); err != nil {
+		log.Printf("Ошибка загрузки очереди %d: %v", queueID, err)
+		return
+	}
+
+	rows, err := db.Query(`
+	SELECT user_id FROM queue_subscribers WHERE queue_id = ?
+	UNION
+	SELECT user_id FROM queue_entries WHERE queue_id = ? AND user_id != 0`, queueID, queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки подписчиков очереди %d: %v", queueID, err)
+		return
+	}
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			log.Printf("Ошибка загрузки подписчиков очереди %d: %v", queueID, err)
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	// Группа очереди тоже узнаёт об открытии и закрытии, если группы включены
+	if groupChatID != 0 && config.Features.Groups {
+		userIDs = append(userIDs, groupChatID)
+	}
+
+	// Подписчиков может быть много, поэтому рассылаем в фоне
+	if _, err := enqueueBroadcast(0, name, userIDs, fmt.Sprintf(format, name)); err != nil {
+		log.Printf("Ошибка рассылки по очереди %d: %v", queueID, err)
+	}
+}
+
+// Подписка на уведомления об открытии и закрытии очереди
+func subscribeToQueue(userID int64, queueID int) error {
+	_, err := db.Exec("INSERT OR IGNORE INTO queue_subscribers (queue_id, user_id) VALUES (?, ?)", queueID, userID)
+	return err
+}
+
+// Кнопка подписки для закрытой очереди
+func subscribeKeyboard(queueID int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Уведомить об открытии", fmt.Sprintf("subscribe:%d", queueID)),
+	))
+}
+
+// Текст отказа в записи в неоткрытую очередь
+func queueClosedText(q Queue) string {
+	if !q.OpensAt.IsZero() {
+		return fmt.Sprintf("Очередь ещё не открыта. Запись начнётся %s.", formatTime(q.OpensAt))
+	}
+	if q.Status == queueStatusDraft {
+		return "Очередь ещё не открыта."
+	}
+	if q.Status == queueStatusArchived {
+		return "Занятие уже прошло, очередь в архиве."
+	}
+	return "Очередь закрыта, записаться сейчас нельзя."
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Ограничения Telegram: около 30 сообщений в секунду всего
+// и не больше одного в секунду в один чат (небольшие всплески допустимы).
+// Это значения по умолчанию, их можно изменить в rate_limits.
+const (
+	globalRate  = 30
+	globalBurst = 30
+	chatRate    = 1
+	chatBurst   = 3
+
+	// Дольше этого ответ на действие пользователя не ждёт:
+	// при большем retry_after сообщение считается неотправленным
+	maxRetryWait = 5 * time.Second
+	maxRetries   = 3
+)
+
+// Ведро токенов: rate токенов в секунду, не больше burst сразу
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Резервирует токен и возвращает, сколько нужно подождать до отправки.
+// Токены могут уйти в минус: так отправки выстраиваются друг за другом.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Запрет отправки до момента until (после ответа 429)
+func (b *tokenBucket) pause(until time.Time) {
+	if until.After(b.last) {
+		b.tokens = 0
+		b.last = until
+	}
+}
+
+// Ограничитель исходящих сообщений, общий для всех горутин
+type rateLimiter struct {
+	mu        sync.Mutex
+	global    *tokenBucket
+	chats     map[int64]*tokenBucket
+	chatRate  float64
+	chatBurst float64
+}
+
+// Пересоздаётся в main по настройкам rate_limits
+var outbound = newRateLimiter(globalRate, globalBurst, chatRate, chatBurst)
+
+func newRateLimiter(globalRate, globalBurst, chatRate, chatBurst float64) *rateLimiter {
+	return &rateLimiter{
+		global:    newTokenBucket(globalRate, globalBurst),
+		chats:     make(map[int64]*tokenBucket),
+		chatRate:  chatRate,
+		chatBurst: chatBurst,
+	}
+}
+
+func (l *rateLimiter) chat(chatID int64) *tokenBucket {
+	b, ok := l.chats[chatID]
+	if !ok {
+		b = newTokenBucket(l.chatRate, l.chatBurst)
+		l.chats[chatID] = b
+	}
+	return b
+}
+
+// Ожидание своей очереди на отправку в чат
+func (l *rateLimiter) wait(chatID int64) {
+	l.mu.Lock()
+	now := time.Now()
+	delay := l.global.reserve(now)
+	if chatID != 0 {
+		delay = max(delay, l.chat(chatID).reserve(now))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+func (l *rateLimiter) pause(chatID int64, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.chat(chatID).pause(time.Now().Add(d))
+}
+
+// Чат, в который уходит сообщение; 0 — неизвестен
+func chattableChatID(c tgbotapi.Chattable) int64 {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		return c.ChatID
+	case tgbotapi.CopyMessageConfig:
+		return c.ChatID
+	case tgbotapi.ForwardConfig:
+		return c.ChatID
+	case tgbotapi.DocumentConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return c.ChatID
+	}
+	return 0
+}
+
+// Сколько Telegram просит подождать; 0 — ошибка не про лимиты
+func retryAfter(err error) time.Duration {
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+		return time.Duration(tgErr.RetryAfter) * time.Second
+	}
+	return 0
+}
+
+// Отправка с учётом лимитов Telegram. При ответе 429 ждёт retry_after
+// и повторяет, если ждать недолго. Ошибки записываются в лог.
+func send(bot *tgbotapi.BotAPI, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	chatID := chattableChatID(c)
+	for attempt := 1; ; attempt++ {
+		outbound.wait(chatID)
+		sent, err := bot.Send(c)
+		if err == nil {
+			return sent, nil
+		}
+
+		wait := retryAfter(err)
+		if wait > 0 && chatID != 0 {
+			outbound.pause(chatID, wait)
+		}
+		if wait == 0 || wait > maxRetryWait || attempt >= maxRetries {
+			log.Printf("Ошибка отправки в чат %d: %v", chatID, err)
+			return sent, err
+		}
+	}
+}
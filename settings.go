@@ -0,0 +1,388 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	queueStatusDraft  = "draft"
+	queueStatusOpen   = "open"
+	queueStatusClosed = "closed"
+)
+
+var queueStatusNames = map[string]string{
+	queueStatusDraft:    "черновик",
+	queueStatusOpen:     "открыта",
+	queueStatusClosed:   "закрыта",
+	queueStatusArchived: "архив",
+}
+
+var settingsField = make(map[int64]string) // userID -> редактируемое поле настроек
+
+// Очередь со всеми настройками
+type Queue struct {
+	ID            int
+	Name          string
+	CreatedBy     int64
+	Description   string
+	Subject       string
+	Teacher       string
+	Room          string
+	Labs          []int // номера лаб, которые принимаются в очереди
+	MaxSize       int   // 0 — без ограничения
+	AllowMultiple bool  // можно ли записаться несколько раз
+	Status        string
+	OpensAt       time.Time // плановое открытие, нулевое — не запланировано
+	ClosesAt      time.Time // плановое закрытие, нулевое — не запланировано
+	OrderPolicy   string
+	MaxDefers     int   // сколько раз можно пропустить других вперёд
+	CallTimeout   int   // минут на подтверждение вызова, 0 — без ожидания
+	MaxNoShows    int   // после скольких неявок удалять из очереди, 0 — никогда
+	NotifyAt      []int // на каких местах присылать уведомления
+	CourseID      int   // курс, которому принадлежит очередь; 0 — общая
+	GroupChatID   int64 // группа в Telegram, к которой привязана очередь; 0 — нет
+	GroupTitle    string
+}
+
+const queueColumns = "id, name, created_by, description, subject, teacher, room, labs, max_size, allow_multiple, status, opens_at, closes_at, order_policy, max_defers, call_timeout, max_no_shows, notify_positions, COALESCE(course_id, 0), COALESCE(group_chat_id, 0), group_title"
+
+func scanQueue(row interface{ Scan(...any) error }) (Queue, error) {
+	var q Queue
+	var createdBy sql.NullInt64
+	var labs, notifyAt string
+	var opensAt, closesAt sql.NullTime
+	err := row.Scan(&q.ID, &q.Name, &createdBy, &q.Description, &q.Subject, &q.Teacher, &q.Room,
+		&labs, &q.MaxSize, &q.AllowMultiple, &q.Status, &opensAt, &closesAt, &q.OrderPolicy, &q.MaxDefers, &q.CallTimeout, &q.MaxNoShows, &notifyAt, &q.CourseID, &q.GroupChatID, &q.GroupTitle)
+	q.CreatedBy = createdBy.Int64
+	q.OpensAt = opensAt.Time
+	q.ClosesAt = closesAt.Time
+	q.Labs, _ = parseLabList(labs)
+	q.NotifyAt, _ = parseNotifyPositions(notifyAt)
+	return q, err
+}
+
+// Загрузка очереди по ID
+func loadQueue(queueID int) (Queue, error) {
+	return scanQueue(db.QueryRow("SELECT "+queueColumns+" FROM queues WHERE id = ?", queueID))
+}
+
+// Разбор списка номеров лаб: "1, 2, 5-7"
+func parseLabList(text string) ([]int, error) {
+	var labs []int
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == ';' }) {
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(from)
+		if err != nil || first <= 0 {
+			return nil, fmt.Errorf("не понимаю номер лабы %q", part)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(to)
+			if err != nil || last < first || last-first > 50 {
+				return nil, fmt.Errorf("неверный диапазон %q", part)
+			}
+		}
+		for n := first; n <= last; n++ {
+			if !slices.Contains(labs, n) {
+				labs = append(labs, n)
+			}
+		}
+	}
+	slices.Sort(labs)
+	return labs, nil
+}
+
+func formatLabList(labs []int) string {
+	parts := make([]string, len(labs))
+	for i, n := range labs {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Описание очереди для показа пользователю
+func formatQueueInfo(q Queue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Очередь \"%s\"", q.Name)
+	if q.Status != queueStatusOpen {
+		fmt.Fprintf(&b, " (%s)", queueStatusNames[q.Status])
+	}
+	b.WriteString("\n")
+	if !q.OpensAt.IsZero() {
+		b.WriteString("Откроется: " + formatTime(q.OpensAt) + "\n")
+	}
+	if !q.ClosesAt.IsZero() {
+		b.WriteString("Закроется: " + formatTime(q.ClosesAt) + "\n")
+	}
+	if q.Description != "" {
+		b.WriteString(q.Description + "\n")
+	}
+	if q.Subject != "" {
+		b.WriteString("Предмет: " + q.Subject + "\n")
+	}
+	if q.Teacher != "" {
+		b.WriteString("Преподаватель: " + q.Teacher + "\n")
+	}
+	if q.Room != "" {
+		b.WriteString("Аудитория: " + q.Room + "\n")
+	}
+	if len(q.Labs) > 0 {
+		b.WriteString("Принимаются лабы: " + formatLabList(q.Labs) + "\n")
+	}
+	if q.MaxSize > 0 {
+		fmt.Fprintf(&b, "Мест: %d\n", q.MaxSize)
+	}
+	return b.String()
+}
+
+// Поле настроек очереди
+type queueSetting struct {
+	button string
+	column string
+	prompt string
+	// parse проверяет введённый текст и возвращает значение для записи в базу
+	parse func(text string) (any, error)
+	// options — варианты ответа для клавиатуры, если поле не свободное
+	options []string
+}
+
+var queueSettings = []queueSetting{
+	{"Описание", "description", "Введите описание очереди:", parseSettingText(500), nil},
+	{"Предмет", "subject", "Введите название предмета:", parseSettingText(100), nil},
+	{"Преподаватель", "teacher", "Введите ФИО преподавателя:", parseSettingText(100), nil},
+	{"Аудитория", "room", "Введите номер аудитории:", parseSettingText(30), nil},
+	{"Номера лаб", "labs", "Введите номера принимаемых лаб через запятую (например: 1, 2, 4-6):", func(text string) (any, error) {
+		labs, err := parseLabList(text)
+		if err != nil {
+			return nil, err
+		}
+		return formatLabList(labs), nil
+	}, nil},
+	{"Макс. размер", "max_size", "Введите максимальное число записей в очереди (0 — без ограничения):", parseSettingInt(0, 1000), nil},
+	{"Лимит пропусков", "max_defers", "Сколько раз каждый может пропустить других вперёд (0 — нельзя):", parseSettingInt(0, 100), nil},
+	{"Время на ответ", "call_timeout", "Сколько минут даётся на подтверждение вызова (0 — не ждать подтверждения):", parseSettingInt(0, 60), nil},
+	{"Лимит неявок", "max_no_shows", "После скольких неявок удалять из очереди (0 — не удалять):", parseSettingInt(0, 10), nil},
+	{"Уведомления", "notify_positions", "На каких местах напоминать о подходе очереди (например: 2, 3, 5; пусто — не напоминать):", func(text string) (any, error) {
+		positions, err := parseNotifyPositions(text)
+		if err != nil {
+			return nil, err
+		}
+		return formatNotifyPositions(positions), nil
+	}, nil},
+	{"Статус", "status", "Выберите статус очереди:", func(text string) (any, error) {
+		for status, name := range queueStatusNames {
+			if strings.EqualFold(text, name) {
+				return status, nil
+			}
+		}
+		return nil, errors.New("выберите статус на клавиатуре")
+	}, []string{"открыта", "закрыта", "черновик", "архив"}},
+	{"Порядок", "order_policy", "Выберите порядок очереди:", func(text string) (any, error) {
+		for policy, name := range orderPolicyNames {
+			if strings.EqualFold(text, name) {
+				return policy, nil
+			}
+		}
+		return nil, errors.New("выберите порядок на клавиатуре")
+	}, []string{"по времени записи", "случайный", "приоритет"}},
+	{"Открытие", "opens_at", "Введите дату и время открытия в формате " + timeInputHint + ":", parseSettingTime, nil},
+	{"Закрытие", "closes_at", "Введите дату и время закрытия в формате " + timeInputHint + ":", parseSettingTime, nil},
+}
+
+func parseSettingText(maxLen int) func(string) (any, error) {
+	return func(text string) (any, error) {
+		if len([]rune(text)) > maxLen {
+			return nil, fmt.Errorf("слишком длинно, максимум %d символов", maxLen)
+		}
+		return text, nil
+	}
+}
+
+// Пустое значение означает 0
+func parseSettingInt(minValue, maxValue int) func(string) (any, error) {
+	return func(text string) (any, error) {
+		if text == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(text)
+		if err != nil || n < minValue || n > maxValue {
+			return nil, fmt.Errorf("нужно целое число от %d до %d", minValue, maxValue)
+		}
+		return n, nil
+	}
+}
+
+// Пустое значение снимает расписание
+func parseSettingTime(text string) (any, error) {
+	if text == "" {
+		return nil, nil
+	}
+	t, err := parseTime(text)
+	if err != nil {
+		return nil, fmt.Errorf("ожидается формат %s", timeInputHint)
+	}
+	return t.UTC(), nil
+}
+
+// Показ настроек очереди администратору
+func showQueueSettings(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при загрузке очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		delete(userStates, chatID)
+		return
+	}
+
+	userStates[chatID] = "admin_settings"
+
+	allowMultiple := "нет"
+	if q.AllowMultiple {
+		allowMultiple = "да"
+	}
+	text := formatQueueInfo(q) +
+		fmt.Sprintf("\nПовторная запись: %s\nСтатус: %s\nПорядок: %s\nЛимит пропусков: %d\nВремя на ответ: %d мин.\nЛимит неявок: %d\nУведомления на местах: %s\n\nВыберите, что изменить:",
+			allowMultiple, queueStatusNames[q.Status], orderPolicyNames[q.OrderPolicy], q.MaxDefers, q.CallTimeout, q.MaxNoShows,
+			cmp.Or(formatNotifyPositions(q.NotifyAt), "нет"))
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = settingsKeyboard()
+	send(bot, msg)
+}
+
+func settingsKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	var buttons [][]tgbotapi.KeyboardButton
+	for i := 0; i < len(queueSettings); i += 2 {
+		row := tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(queueSettings[i].button))
+		if i+1 < len(queueSettings) {
+			row = append(row, tgbotapi.NewKeyboardButton(queueSettings[i+1].button))
+		}
+		buttons = append(buttons, row)
+	}
+	buttons = append(buttons,
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Повторная запись")),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+	)
+	return tgbotapi.NewReplyKeyboard(buttons...)
+}
+
+// Выбор поля в меню настроек
+func handleSettingsMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID, exists := selectedQueueID[chatID]
+	if !exists {
+		msg := tgbotapi.NewMessage(chatID, "Ошибка: очередь не выбрана.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		delete(userStates, chatID)
+		return
+	}
+
+	switch message.Text {
+	case "Назад в главное меню":
+		delete(userStates, chatID)
+		delete(selectedQueueID, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	case "Повторная запись":
+		updateQueueSetting(bot, chatID, queueID, "UPDATE queues SET allow_multiple = NOT allow_multiple WHERE id = ?")
+		return
+	}
+
+	i := slices.IndexFunc(queueSettings, func(s queueSetting) bool { return s.button == message.Text })
+	if i < 0 {
+		msg := tgbotapi.NewMessage(chatID, "Неверная команда. Используйте меню.")
+		send(bot, msg)
+		return
+	}
+
+	userStates[chatID] = "admin_settings_value"
+	settingsField[chatID] = queueSettings[i].column
+	msg := tgbotapi.NewMessage(chatID, queueSettings[i].prompt)
+	back := tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад"))
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(back)
+	if options := queueSettings[i].options; options != nil {
+		var row []tgbotapi.KeyboardButton
+		for _, option := range options {
+			row = append(row, tgbotapi.NewKeyboardButton(option))
+		}
+		msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(row, back)
+	} else {
+		msg.Text += "\nОтправьте «-», чтобы очистить поле."
+	}
+	send(bot, msg)
+}
+
+// Ввод нового значения поля настроек
+func handleSettingsValue(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+	column := settingsField[chatID]
+	delete(settingsField, chatID)
+
+	i := slices.IndexFunc(queueSettings, func(s queueSetting) bool { return s.column == column })
+	if i < 0 {
+		showQueueSettings(bot, chatID, queueID)
+		return
+	}
+	setting := queueSettings[i]
+
+	text := strings.TrimSpace(message.Text)
+	// Отмена: возвращаемся к настройкам, ничего не меняя
+	if text == "Назад" || text == "/cancel" {
+		showQueueSettings(bot, chatID, queueID)
+		return
+	}
+	if text == "-" {
+		text = ""
+	}
+
+	value, err := setting.parse(text)
+	if err != nil {
+		settingsField[chatID] = column
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка: %v. Попробуйте снова или нажмите «Назад»:", err))
+		send(bot, msg)
+		return
+	}
+
+	before, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+	}
+	statusChanged := setting.column == "status" && err == nil && value != before.Status
+
+	// Имя колонки берётся из queueSettings, а не от пользователя
+	saved := updateQueueSetting(bot, chatID, queueID, "UPDATE queues SET "+setting.column+" = ? WHERE id = ?", value)
+
+	// Смена статуса вручную объявляется так же, как по расписанию
+	if saved && statusChanged {
+		queueStatusChanged(queueID, value.(string))
+	}
+}
+
+// Сохранение настройки и возврат к меню настроек. Возвращает, удалось ли сохранить.
+func updateQueueSetting(bot *tgbotapi.BotAPI, chatID int64, queueID int, query string, args ...any) bool {
+	args = append(args, queueID)
+	_, err := db.Exec(query, args...)
+	if err != nil {
+		log.Printf("Ошибка изменения настроек очереди: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при сохранении настроек.")
+		send(bot, msg)
+	}
+	showQueueSettings(bot, chatID, queueID)
+	return err == nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// «Назад» и /cancel в ответ на вопрос о значении ничего не сохраняют
+func TestSettingsValueCancel(t *testing.T) {
+	setupTestDB(t)
+	bot, _ := newTestBot(t)
+	const admin = 10
+	q := createTestQueue(t, "Лабы")
+	t.Cleanup(func() {
+		delete(userStates, admin)
+		delete(selectedQueueID, admin)
+		delete(settingsField, admin)
+	})
+
+	for _, text := range []string{"Назад", "/cancel"} {
+		for _, column := range []string{"description", "status"} {
+			selectedQueueID[admin] = q.ID
+			userStates[admin] = "admin_settings_value"
+			settingsField[admin] = column
+
+			handleSettingsValue(bot, &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: admin}, Text: text})
+
+			after, err := loadQueue(q.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Description != q.Description || after.Status != q.Status {
+				t.Errorf("%s в поле %s изменило очередь: %+v", text, column, after)
+			}
+			if userStates[admin] != "admin_settings" {
+				t.Errorf("%s в поле %s: состояние %q, ожидался возврат к настройкам", text, column, userStates[admin])
+			}
+			if _, waiting := settingsField[admin]; waiting {
+				t.Errorf("%s в поле %s: бот всё ещё ждёт значение", text, column)
+			}
+		}
+	}
+}
+
+// Статус, изменённый в настройках, объявляется подписчикам, как при открытии по расписанию
+func TestSettingsStatusAnnounced(t *testing.T) {
+	setupTestDB(t)
+	bot, _ := newTestBot(t)
+	const admin, subscriber = 10, 20
+	q := createTestQueue(t, "Лабы")
+	if _, err := db.Exec("UPDATE queues SET status = ? WHERE id = ?", queueStatusClosed, q.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := subscribeToQueue(subscriber, q.ID); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		delete(userStates, admin)
+		delete(selectedQueueID, admin)
+		delete(settingsField, admin)
+	})
+
+	selectedQueueID[admin] = q.ID
+	userStates[admin] = "admin_settings_value"
+	settingsField[admin] = "status"
+	handleSettingsValue(bot, &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: admin}, Text: queueStatusNames[queueStatusOpen]})
+
+	if q, err := loadQueue(q.ID); err != nil || q.Status != queueStatusOpen {
+		t.Fatalf("статус %q, err = %v", q.Status, err)
+	}
+	var text string
+	if err := db.QueryRow("SELECT text FROM outbox WHERE chat_id = ?", subscriber).Scan(&text); err != nil {
+		t.Fatalf("подписчику ничего не отправлено: %v", err)
+	}
+	if text != `Очередь "Лабы" открыта, можно записываться!` {
+		t.Errorf("подписчику отправлено %q", text)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Сколько действует предложение поменяться местами
+const swapRequestTimeout = 10 * time.Minute
+
+var errSwapUnavailable = errors.New("предложение больше не действует")
+
+// Выбор, с кем поменяться, в выбранной очереди
+func startSwap(bot *tgbotapi.BotAPI, chatID int64, queueID int) {
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при получении списка очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	if !slices.ContainsFunc(entries, func(e QueueEntry) bool { return e.UserID == chatID }) {
+		msg := tgbotapi.NewMessage(chatID, "Вас нет в этой очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	others := slices.DeleteFunc(slices.Clone(entries), func(e QueueEntry) bool { return e.UserID == chatID })
+	if len(others) == 0 {
+		msg := tgbotapi.NewMessage(chatID, "Кроме вас в очереди никого нет.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	// Кнопки с настоящими номерами мест, без своих записей
+	var buttons [][]tgbotapi.KeyboardButton
+	for i, e := range entries {
+		if e.UserID != chatID {
+			buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(
+				tgbotapi.NewKeyboardButton(fmt.Sprintf("%d. %s", i+1, entryLabel(e))),
+			))
+		}
+	}
+	buttons = append(buttons, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")))
+
+	userStates[chatID] = "swap_select"
+	selectedQueueID[chatID] = queueID
+	msg := tgbotapi.NewMessage(chatID, "С кем поменяться местами?")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
+	send(bot, msg)
+}
+
+func handleSwapSelect(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queueID := selectedQueueID[chatID]
+
+	defer func() {
+		if userStates[chatID] != "swap_select" {
+			delete(selectedQueueID, chatID)
+		}
+	}()
+
+	if message.Text == "Назад в главное меню" {
+		delete(userStates, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	entries, err := loadEntries(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+		delete(userStates, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при получении списка очереди.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	target, ok := entryFromButton(entries, message.Text)
+	ownIndex := slices.IndexFunc(entries, func(e QueueEntry) bool { return e.UserID == chatID })
+	if !ok || target.UserID == chatID || ownIndex < 0 {
+		msg := tgbotapi.NewMessage(chatID, "Выберите участника на клавиатуре.")
+		send(bot, msg)
+		return
+	}
+	own := entries[ownIndex]
+	targetIndex := slices.IndexFunc(entries, func(e QueueEntry) bool { return e.ID == target.ID })
+
+	delete(userStates, chatID)
+
+	if target.UserID == 0 {
+		msg := tgbotapi.NewMessage(chatID, "Этот участник не писал боту, отправить ему предложение не получится.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	q, err := loadQueue(queueID)
+	if err != nil {
+		log.Printf("Ошибка загрузки очереди: %v", err)
+	}
+
+	res, err := db.Exec(`INSERT INTO swap_requests (queue_id, from_entry_id, to_entry_id, from_user_id, to_user_id, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?)`, queueID, own.ID, target.ID, chatID, target.UserID, time.Now().Add(swapRequestTimeout).UTC())
+	if err != nil {
+		log.Printf("Ошибка создания обмена: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при отправке предложения.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+	requestID, _ := res.LastInsertId()
+
+	offer := tgbotapi.NewMessage(target.UserID, fmt.Sprintf(
+		"%s предлагает поменяться местами в очереди \"%s\": вы встанете на %d место вместо %d.\nПредложение действует %d минут.",
+		entryLabel(own), q.Name, ownIndex+1, targetIndex+1, int(swapRequestTimeout.Minutes())))
+	offer.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Согласиться", fmt.Sprintf("swap_accept:%d", requestID)),
+		tgbotapi.NewInlineKeyboardButtonData("Отказаться", fmt.Sprintf("swap_decline:%d", requestID)),
+	))
+	sent, err := send(bot, offer)
+	if err != nil {
+		log.Printf("Ошибка отправки предложения обмена: %v", err)
+		db.Exec("UPDATE swap_requests SET status = 'failed' WHERE id = ?", requestID)
+		msg := tgbotapi.NewMessage(chatID, "Не удалось отправить предложение: возможно, участник остановил бота.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+	db.Exec("UPDATE swap_requests SET message_id = ? WHERE id = ?", sent.MessageID, requestID)
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Предложение отправлено %s. Сообщу, когда придёт ответ.", entryLabel(target)))
+	msg.ReplyMarkup = mainMenu()
+	send(bot, msg)
+}
+
+type swapRequest struct {
+	ID          int
+	QueueID     int
+	FromEntryID int
+	ToEntryID   int
+	FromUserID  int64
+	ToUserID    int64
+	MessageID   int
+}
+
+func loadSwapRequest(tx queryer, requestID int) (swapRequest, error) {
+	var r swapRequest
+	var messageID sql.NullInt64
+	err := tx.QueryRow(`SELECT id, queue_id, from_entry_id, to_entry_id, from_user_id, to_user_id, message_id
+	FROM swap_requests WHERE id = ? AND status = 'pending' AND expires_at > ?`, requestID, time.Now().UTC()).
+		Scan(&r.ID, &r.QueueID, &r.FromEntryID, &r.ToEntryID, &r.FromUserID, &r.ToUserID, &messageID)
+	if err == sql.ErrNoRows {
+		err = errSwapUnavailable
+	}
+	r.MessageID = int(messageID.Int64)
+	return r, err
+}
+
+// Обмен местами. Обе записи должны быть на месте.
+func acceptSwap(requestID int, userID int64) (swapRequest, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return swapRequest{}, err
+	}
+	defer tx.Rollback()
+
+	r, err := loadSwapRequest(tx, requestID)
+	if err != nil {
+		return r, err
+	}
+	if r.ToUserID != userID {
+		return r, errSwapUnavailable
+	}
+
+	var fromPosition, toPosition int
+	err = tx.QueryRow("SELECT position FROM queue_entries WHERE id = ? AND queue_id = ?", r.FromEntryID, r.QueueID).Scan(&fromPosition)
+	if err == nil {
+		err = tx.QueryRow("SELECT position FROM queue_entries WHERE id = ? AND queue_id = ?", r.ToEntryID, r.QueueID).Scan(&toPosition)
+	}
+	if err == sql.ErrNoRows {
+		tx.Exec("UPDATE swap_requests SET status = 'failed' WHERE id = ?", r.ID)
+		tx.Commit()
+		return r, errSwapUnavailable
+	}
+	if err != nil {
+		return r, err
+	}
+
+	if _, err := tx.Exec("UPDATE queue_entries SET position = ? WHERE id = ?", toPosition, r.FromEntryID); err != nil {
+		return r, err
+	}
+	if _, err := tx.Exec("UPDATE queue_entries SET position = ? WHERE id = ?", fromPosition, r.ToEntryID); err != nil {
+		return r, err
+	}
+	if _, err := tx.Exec("UPDATE swap_requests SET status = 'accepted' WHERE id = ?", r.ID); err != nil {
+		return r, err
+	}
+	return r, tx.Commit()
+}
+
+func declineSwap(requestID int, userID int64) (swapRequest, error) {
+	r, err := loadSwapRequest(db, requestID)
+	if err != nil {
+		return r, err
+	}
+	if r.ToUserID != userID {
+		return r, errSwapUnavailable
+	}
+	_, err = db.Exec("UPDATE swap_requests SET status = 'declined' WHERE id = ?", r.ID)
+	return r, err
+}
+
+// Ответ на предложение обмена из inline-кнопки
+func handleSwapAnswer(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, requestID int, accept bool) string {
+	userID := callbackQuery.From.ID
+
+	var r swapRequest
+	var err error
+	if accept {
+		r, err = acceptSwap(requestID, userID)
+	} else {
+		r, err = declineSwap(requestID, userID)
+	}
+
+	// Кнопки больше не нужны в любом случае
+	if callbackQuery.Message != nil {
+		send(bot, tgbotapi.NewEditMessageReplyMarkup(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID,
+			tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+	}
+
+	if errors.Is(err, errSwapUnavailable) {
+		return "Предложение больше не действует."
+	}
+	if err != nil {
+		log.Printf("Ошибка обмена местами: %v", err)
+		return "Ошибка при обмене местами."
+	}
+
+	var name string
+	db.QueryRow("SELECT name FROM queues WHERE id = ?", r.QueueID).Scan(&name)
+
+	if !accept {
+		send(bot, tgbotapi.NewMessage(r.FromUserID, fmt.Sprintf("Вам отказали в обмене местами в очереди \"%s\".", name)))
+		return "Вы отказались."
+	}
+
+	text := fmt.Sprintf("Обмен местами в очереди \"%s\" состоялся.", name)
+	send(bot, tgbotapi.NewMessage(r.FromUserID, text))
+	send(bot, tgbotapi.NewMessage(r.ToUserID, text))
+	return "Готово!"
+}
+
+// Истечение неотвеченных предложений обмена
+func expireSwapRequests(bot *tgbotapi.BotAPI, now time.Time) error {
+	rows, err := db.Query(`SELECT id, from_user_id, to_user_id, message_id FROM swap_requests
+	WHERE status = 'pending' AND expires_at <= ?`, now.UTC())
+	if err != nil {
+		return err
+	}
+	var expired []swapRequest
+	for rows.Next() {
+		var r swapRequest
+		var messageID sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.FromUserID, &r.ToUserID, &messageID); err != nil {
+			rows.Close()
+			return err
+		}
+		r.MessageID = int(messageID.Int64)
+		expired = append(expired, r)
+	}
+	rows.Close()
+
+	for _, r := range expired {
+		res, err := db.Exec("UPDATE swap_requests SET status = 'expired' WHERE id = ? AND status = 'pending'", r.ID)
+		if err != nil {
+			return err
+		}
+		// Пользователь мог ответить, пока мы собирали список
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		if r.MessageID != 0 {
+			send(bot, tgbotapi.NewEditMessageReplyMarkup(r.ToUserID, r.MessageID,
+				tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+		}
+		send(bot, tgbotapi.NewMessage(r.FromUserID, "Предложение поменяться местами осталось без ответа и отменено."))
+	}
+	return nil
+}
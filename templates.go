@@ -0,0 +1,346 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	queueStatusArchived = "archived"
+
+	// На сколько дней вперёд создаются очереди по шаблонам
+	templateLookahead = 7
+
+	// Самое длинное дополнение к названию шаблона: дата с годом и номер,
+	// если такое название уже занято
+	templateNameSuffixLen = len(" 02.01.2006 (9)")
+
+	weekParityAll  = "all"
+	weekParityOdd  = "odd"
+	weekParityEven = "even"
+)
+
+// Генерация может запускаться и из планировщика, и из обработчика сообщений
+var templatesMu sync.Mutex
+
+var weekdayNames = []string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"}
+
+var weekdayFullNames = []string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"}
+
+var weekParityNames = map[string]string{
+	weekParityAll:  "каждую неделю",
+	weekParityOdd:  "по нечётным неделям",
+	weekParityEven: "по чётным неделям",
+}
+
+// Шаблон повторяющейся очереди
+type QueueTemplate struct {
+	ID         int
+	Name       string
+	Weekday    time.Weekday
+	Hour       int
+	Minute     int
+	Parity     string
+	OpenBefore int // за сколько часов до занятия открывать запись, 0 — сразу
+}
+
+func loadTemplates() ([]QueueTemplate, error) {
+	rows, err := db.Query("SELECT id, name, weekday, start_time, parity, open_before FROM queue_templates ORDER BY weekday, start_time")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []QueueTemplate
+	for rows.Next() {
+		var t QueueTemplate
+		var startTime string
+		if err := rows.Scan(&t.ID, &t.Name, &t.Weekday, &startTime, &t.Parity, &t.OpenBefore); err != nil {
+			return nil, err
+		}
+		fmt.Sscanf(startTime, "%d:%d", &t.Hour, &t.Minute)
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// Чётность учебной недели. Если в настройках задано начало семестра,
+// неделя с этой датой считается первой, иначе берётся номер недели ISO.
+func isOddWeek(day time.Time) bool {
+	if start, err := time.ParseInLocation(semesterStartLayout, config.SemesterStart, location); err == nil {
+		// Приводим обе даты к понедельнику своей недели
+		monday := func(t time.Time) time.Time {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+			return t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+		}
+		days := int((monday(day).Sub(monday(start)).Hours() + 12) / 24)
+		weeks := days / 7
+		return weeks%2 == 0
+	}
+	_, week := day.ISOWeek()
+	return week%2 == 1
+}
+
+func (t QueueTemplate) matches(day time.Time) bool {
+	if day.Weekday() != t.Weekday {
+		return false
+	}
+	switch t.Parity {
+	case weekParityOdd:
+		return isOddWeek(day)
+	case weekParityEven:
+		return !isOddWeek(day)
+	}
+	return true
+}
+
+func (t QueueTemplate) describe() string {
+	return fmt.Sprintf("%s — %s %02d:%02d, %s", t.Name, weekdayNames[t.Weekday], t.Hour, t.Minute, weekParityNames[t.Parity])
+}
+
+// Создание очередей по шаблонам на ближайшие дни и архивация прошедших
+func generateTemplateQueues(now time.Time) error {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+
+	templates, err := loadTemplates()
+	if err != nil {
+		return err
+	}
+
+	now = now.In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	for _, t := range templates {
+		for i := 0; i <= templateLookahead; i++ {
+			day := today.AddDate(0, 0, i)
+			if !t.matches(day) {
+				continue
+			}
+			session := time.Date(day.Year(), day.Month(), day.Day(), t.Hour, t.Minute, 0, 0, location)
+			if session.Before(now) {
+				continue
+			}
+			if err := createTemplateQueue(t, session, now); err != nil {
+				log.Printf("Ошибка создания очереди по шаблону %d: %v", t.ID, err)
+			}
+		}
+	}
+
+	// Очереди прошедших дней уходят в архив
+	_, err = db.Exec(`UPDATE queues SET status = ?, opens_at = NULL, closes_at = NULL
+	WHERE session_at IS NOT NULL AND session_at < ? AND status != ?`,
+		queueStatusArchived, today.UTC(), queueStatusArchived)
+	return err
+}
+
+func createTemplateQueue(t QueueTemplate, session, now time.Time) error {
+	var exists int
+	err := db.QueryRow("SELECT COUNT(*) FROM queues WHERE template_id = ? AND session_at = ?", t.ID, session.UTC()).Scan(&exists)
+	if err != nil || exists > 0 {
+		return err
+	}
+
+	// Если название с датой занято (например, очередь прошлого года),
+	// добавляем год, а затем номер
+	name, err := checkQueueName(fmt.Sprintf("%s %s", t.Name, session.Format("02.01")), 0, 0)
+	for i := 1; errors.Is(err, errQueueNameTaken) && i < 10; i++ {
+		candidate := fmt.Sprintf("%s %s", t.Name, session.Format("02.01.2006"))
+		if i > 1 {
+			candidate += fmt.Sprintf(" (%d)", i)
+		}
+		name, err = checkQueueName(candidate, 0, 0)
+	}
+	if err != nil {
+		return err
+	}
+
+	status := queueStatusOpen
+	var opensAt any
+	if t.OpenBefore > 0 {
+		if open := session.Add(-time.Duration(t.OpenBefore) * time.Hour); open.After(now) {
+			status = queueStatusDraft
+			opensAt = open.UTC()
+		}
+	}
+
+	_, err = db.Exec(`INSERT OR IGNORE INTO queues (name, name_key, template_id, session_at, status, opens_at)
+	VALUES (?, ?, ?, ?, ?, ?)`, name, queueNameKey(name), t.ID, session.UTC(), status, opensAt)
+	return err
+}
+
+// Разбор шаблона из строки "Название; день недели; время; неделя; за сколько часов открывать"
+func parseTemplate(text string) (QueueTemplate, error) {
+	parts := strings.Split(text, ";")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if len(parts) < 3 || len(parts) > 5 {
+		return QueueTemplate{}, errors.New("нужно от 3 до 5 частей через «;»")
+	}
+
+	t := QueueTemplate{Parity: weekParityAll}
+
+	name, err := validateQueueName(parts[0])
+	if err != nil {
+		return t, err
+	}
+	// К названию шаблона дописывается дата занятия, и всё вместе должно уложиться в лимит
+	if utf8.RuneCountInString(name)+templateNameSuffixLen > maxQueueNameLen {
+		return t, fmt.Errorf("название шаблона не длиннее %d символов: к нему добавляется дата", maxQueueNameLen-templateNameSuffixLen)
+	}
+	t.Name = name
+
+	day := strings.ToLower(parts[1])
+	t.Weekday = -1
+	for i := range weekdayNames {
+		if day == weekdayNames[i] || day == weekdayFullNames[i] {
+			t.Weekday = time.Weekday(i)
+		}
+	}
+	if t.Weekday < 0 {
+		return t, fmt.Errorf("не понимаю день недели %q", parts[1])
+	}
+
+	start, err := time.Parse("15:04", parts[2])
+	if err != nil {
+		return t, fmt.Errorf("время занятия нужно в формате ЧЧ:ММ")
+	}
+	t.Hour, t.Minute = start.Hour(), start.Minute()
+
+	if len(parts) > 3 && parts[3] != "" {
+		switch strings.ToLower(parts[3]) {
+		case "все", "каждую", "всегда":
+			t.Parity = weekParityAll
+		case "нечёт", "нечет", "нечётная", "нечетная":
+			t.Parity = weekParityOdd
+		case "чёт", "чет", "чётная", "четная":
+			t.Parity = weekParityEven
+		default:
+			return t, fmt.Errorf("неделя может быть «все», «чёт» или «нечёт»")
+		}
+	}
+
+	if len(parts) > 4 && parts[4] != "" {
+		hours, err := strconv.Atoi(parts[4])
+		if err != nil || hours < 0 || hours > 24*14 {
+			return t, fmt.Errorf("часы до открытия — целое число от 0 до %d", 24*14)
+		}
+		t.OpenBefore = hours
+	}
+	return t, nil
+}
+
+// Меню шаблонов расписания
+func showTemplatesMenu(bot *tgbotapi.BotAPI, chatID int64) {
+	templates, err := loadTemplates()
+	if err != nil {
+		log.Printf("Ошибка загрузки шаблонов: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при загрузке расписания.")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+		return
+	}
+
+	userStates[chatID] = "templates_menu"
+
+	text := "Шаблонов расписания пока нет."
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(templates) > 0 {
+		text = "Расписание лабораторных:"
+		for _, t := range templates {
+			text += "\n• " + t.describe()
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Удалить «"+t.Name+"»", fmt.Sprintf("template_delete:%d", t.ID)),
+			))
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	send(bot, msg)
+
+	msg = tgbotapi.NewMessage(chatID, "Выберите действие:")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Добавить шаблон")),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Назад в главное меню")),
+	)
+	send(bot, msg)
+}
+
+func handleTemplatesMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+
+	switch message.Text {
+	case "Добавить шаблон":
+		userStates[chatID] = "template_create"
+		msg := tgbotapi.NewMessage(chatID, "Введите шаблон в формате:\n"+
+			"Название; день недели; время; неделя; за сколько часов открывать запись\n\n"+
+			"Например: ОС лаба; вт; 10:10; нечёт; 24\n"+
+			"Неделя — «все», «чёт» или «нечёт». Последние две части можно не указывать.")
+		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		send(bot, msg)
+	case "Назад в главное меню":
+		delete(userStates, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Возвращаю в главное меню:")
+		msg.ReplyMarkup = mainMenu()
+		send(bot, msg)
+	default:
+		msg := tgbotapi.NewMessage(chatID, "Неверная команда. Используйте меню.")
+		send(bot, msg)
+	}
+}
+
+func handleTemplateCreation(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+
+	t, err := parseTemplate(message.Text)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка: %v. Попробуйте снова:", err))
+		send(bot, msg)
+		return
+	}
+
+	_, err = db.Exec("INSERT INTO queue_templates (name, weekday, start_time, parity, open_before, created_by) VALUES (?, ?, ?, ?, ?, ?)",
+		t.Name, int(t.Weekday), fmt.Sprintf("%02d:%02d", t.Hour, t.Minute), t.Parity, t.OpenBefore, chatID)
+	if err != nil {
+		log.Printf("Ошибка создания шаблона: %v", err)
+		msg := tgbotapi.NewMessage(chatID, "Ошибка при создании шаблона.")
+		send(bot, msg)
+		showTemplatesMenu(bot, chatID)
+		return
+	}
+
+	if err := generateTemplateQueues(time.Now()); err != nil {
+		log.Printf("Ошибка создания очередей по шаблонам: %v", err)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Шаблон добавлен: "+t.describe())
+	send(bot, msg)
+	showTemplatesMenu(bot, chatID)
+}
+
+// Удаление шаблона. Уже созданные по нему очереди остаются.
+func deleteTemplate(templateID int) error {
+	if _, err := db.Exec("UPDATE queues SET template_id = NULL WHERE template_id = ?", templateID); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM queue_templates WHERE id = ?", templateID)
+	return err
+}
+
+// Заголовок группы очередей одного дня
+func formatSessionDate(t time.Time) string {
+	t = t.In(location)
+	return fmt.Sprintf("%s, %s", weekdayFullNames[t.Weekday()], t.Format("02.01"))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Запоминаем пользователя, чтобы находить его ID по username
+func rememberUser(user *tgbotapi.User) {
+	if user == nil {
+		return
+	}
+	_, err := db.Exec(`INSERT INTO users (user_id, username, first_name, last_name, updated_at)
+	VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	ON CONFLICT(user_id) DO UPDATE SET username = excluded.username, first_name = excluded.first_name,
+		last_name = excluded.last_name, updated_at = excluded.updated_at`,
+		user.ID, user.UserName, user.FirstName, user.LastName)
+	if err != nil {
+		log.Printf("Ошибка сохранения пользователя: %v", err)
+	}
+}
+
+// Поиск ID пользователя по username. Возвращает 0, если бот его не знает.
+func findUserID(username string) (int64, error) {
+	var userID int64
+	err := db.QueryRow("SELECT user_id FROM users WHERE username = ? COLLATE NOCASE",
+		strings.TrimPrefix(strings.TrimSpace(username), "@")).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return userID, err
+}
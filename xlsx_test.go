@@ -0,0 +1,166 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Сборка XLSX из готовых частей: имя файла в архиве -> содержимое
+func buildXLSX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func sheetXML(rows string) string {
+	return `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + rows + `</sheetData></worksheet>`
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"A1", 0},
+		{"Z9", 25},
+		{"AA1", 26},
+		{"AB12", 27},
+		{"XFD1", 16383},
+		{"XFE1", -1},
+		{"AAAAAAAAAAAAAAAAAAAA1", -1},
+		{"1", -1},
+		{"", -1},
+		{"a1", -1},
+	}
+	for _, tt := range tests {
+		if got := columnIndex(tt.ref); got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, want %d", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	shared := `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<si><t>ФИО</t></si><si><r><t>Ива</t></r><r><t>нов Иван</t></r></si></sst>`
+
+	tests := []struct {
+		name    string
+		parts   map[string]string
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "общие строки, пропуски строк и столбцов",
+			parts: map[string]string{
+				"xl/sharedStrings.xml": shared,
+				"xl/worksheets/sheet1.xml": sheetXML(
+					`<row r="1"><c r="A1" t="s"><v>0</v></c></row>` +
+						`<row r="3"><c r="A3" t="s"><v>1</v></c><c r="C3"><v>21</v></c></row>`),
+			},
+			want: [][]string{{"ФИО"}, nil, {"Иванов Иван", "", "21"}},
+		},
+		{
+			name: "строки внутри ячейки и ячейки без адреса",
+			parts: map[string]string{
+				"xl/worksheets/sheet1.xml": sheetXML(
+					`<row><c t="inlineStr"><is><t>Петрова Мария</t></is></c><c><v>22</v></c></row>`),
+			},
+			want: [][]string{{"Петрова Мария", "22"}},
+		},
+		{
+			name: "лист по связям книги",
+			parts: map[string]string{
+				"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+					`<sheets><sheet name="Список" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+				"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+					`<Relationship Id="rId7" Target="worksheets/list.xml"/></Relationships>`,
+				"xl/worksheets/list.xml": sheetXML(`<row r="1"><c r="B1" t="inlineStr"><is><t>x</t></is></c></row>`),
+			},
+			want: [][]string{{"", "x"}},
+		},
+		{
+			name:    "адрес ячейки без букв",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheetXML(`<row r="1"><c r="1"><v>1</v></c></row>`)},
+			wantErr: true,
+		},
+		{
+			name:    "столбец за пределами Excel",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheetXML(`<row r="1"><c r="ZZZZZZ1"><v>1</v></c></row>`)},
+			wantErr: true,
+		},
+		{
+			name:    "отрицательный номер строки",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheetXML(`<row r="-3"><c r="A1"><v>1</v></c></row>`)},
+			wantErr: true,
+		},
+		{
+			name:    "слишком много строк",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheetXML(`<row r="5001"><c r="A5001"><v>1</v></c></row>`)},
+			wantErr: true,
+		},
+		{
+			name:    "ссылка на несуществующую общую строку",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheetXML(`<row r="1"><c r="A1" t="s"><v>5</v></c></row>`)},
+			wantErr: true,
+		},
+		{
+			name:    "нет листа",
+			parts:   map[string]string{"xl/other.xml": "<x/>"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readXLSX(buildXLSX(t, tt.parts))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ожидалась ошибка, получено %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadXLSXNotZip(t *testing.T) {
+	if _, err := readXLSX([]byte("не архив")); !errors.Is(err, errXLSXFormat) {
+		t.Errorf("ожидалась errXLSXFormat, получено %v", err)
+	}
+}
+
+func TestWriteXLSXRoundTrip(t *testing.T) {
+	rows := [][]string{{"ФИО", "Комментарий"}, {"Иванов Иван", `<b>&"x"`}, {"", "", "третий"}}
+	data, err := writeXLSX("Очередь", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readXLSX(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("получено %q, ожидалось %q", got, rows)
+	}
+}